@@ -0,0 +1,283 @@
+package mycache
+
+import (
+	"math"
+	"math/bits"
+	"strconv"
+)
+
+// Bits are numbered from the most significant bit of the first byte, like in Redis.
+
+// BitUnit is the unit of the offsets of a bit range
+type BitUnit int
+
+const (
+	// UnitByte ranges are in bytes
+	UnitByte BitUnit = iota
+	// UnitBit ranges are in bits
+	UnitBit
+)
+
+// BitOperation is a bitwise operation for BitOp
+type BitOperation int
+
+const (
+	BitAnd BitOperation = iota
+	BitOr
+	BitXor
+	BitNot
+)
+
+// getBit returns the bit at offset, 0 past the end of b
+func getBit(b []byte, offset uint64) int {
+	if offset/8 >= uint64(len(b)) {
+		return 0
+	}
+	return int(b[offset/8]>>(7-offset%8)) & 1
+}
+
+// setBit sets the bit at offset, which must be within b, and returns its old value
+func setBit(b []byte, offset uint64, value int) int {
+	old := getBit(b, offset)
+	mask := byte(0x80) >> (offset % 8)
+	if value == 0 {
+		b[offset/8] &^= mask
+	} else {
+		b[offset/8] |= mask
+	}
+	return old
+}
+
+// bitRange converts the inclusive range [start, end] in unit to the half-open range of bits [lo, hi).
+// Negative offsets count from the end. ok is false if the range is empty.
+func bitRange(n int, start, end int, unit BitUnit) (lo, hi uint64, ok bool) {
+	if unit == UnitBit {
+		l, h, ok := normalizeRange(start, end, 8*n)
+		return uint64(l), uint64(h), ok
+	}
+	l, h, ok := normalizeRange(start, end, n)
+	return 8 * uint64(l), 8 * uint64(h), ok
+}
+
+// countBits returns the number of bits set in the bits [lo, hi) of b
+func countBits(b []byte, lo, hi uint64) int {
+	n := 0
+	for ; lo < hi && lo%8 != 0; lo++ {
+		n += getBit(b, lo)
+	}
+	for ; hi > lo && hi%8 != 0; hi-- {
+		n += getBit(b, hi-1)
+	}
+	for _, c := range b[lo/8 : hi/8] {
+		n += bits.OnesCount8(c)
+	}
+	return n
+}
+
+// findBit returns the offset of the first bit set to bit in the bits [lo, hi) of b, or -1
+func findBit(b []byte, bit int, lo, hi uint64) int64 {
+	// skip whole bytes that can't match
+	skip := byte(0)
+	if bit == 0 {
+		skip = 0xff
+	}
+	for lo < hi {
+		if lo%8 == 0 && hi-lo >= 8 && b[lo/8] == skip {
+			lo += 8
+			continue
+		}
+		if getBit(b, lo) == bit {
+			return int64(lo)
+		}
+		lo++
+	}
+	return -1
+}
+
+// bitOp applies op to srcs, the shorter ones being padded with zero bytes
+func bitOp(op BitOperation, srcs [][]byte) []byte {
+	n := 0
+	for _, src := range srcs {
+		n = max(n, len(src))
+	}
+	res := make([]byte, n)
+	if op == BitNot {
+		for i, c := range srcs[0] {
+			res[i] = ^c
+		}
+		return res
+	}
+
+	copy(res, srcs[0])
+	for _, src := range srcs[1:] {
+		for i := range res {
+			var c byte
+			if i < len(src) {
+				c = src[i]
+			}
+			switch op {
+			case BitAnd:
+				res[i] &= c
+			case BitOr:
+				res[i] |= c
+			case BitXor:
+				res[i] ^= c
+			}
+		}
+	}
+	if op == BitAnd {
+		// bytes past the end of the first source are and-ed with its zero padding
+		clear(res[len(srcs[0]):])
+	}
+	return res
+}
+
+// BitFieldType is the type of an integer stored in a bitmap, either signed with up to 64 bits,
+// or unsigned with up to 63 bits
+type BitFieldType struct {
+	Signed bool
+	Bits   int
+}
+
+// ParseBitFieldType parses a type in the syntax of BITFIELD, e.g. "i5" or "u8"
+func ParseBitFieldType(s string) (BitFieldType, error) {
+	if len(s) < 2 || (s[0] != 'i' && s[0] != 'u') {
+		return BitFieldType{}, ErrInvalidBitFieldType
+	}
+	n, err := strconv.Atoi(s[1:])
+	if err != nil {
+		return BitFieldType{}, ErrInvalidBitFieldType
+	}
+	t := BitFieldType{Signed: s[0] == 'i', Bits: n}
+	return t, t.validate()
+}
+
+func (t BitFieldType) validate() error {
+	if t.Bits < 1 || t.Bits > 64 || (!t.Signed && t.Bits == 64) {
+		return ErrInvalidBitFieldType
+	}
+	return nil
+}
+
+// bounds returns the smallest and largest values of t
+func (t BitFieldType) bounds() (int64, int64) {
+	if !t.Signed {
+		return 0, 1<<t.Bits - 1
+	}
+	if t.Bits == 64 {
+		return math.MinInt64, math.MaxInt64
+	}
+	return -1 << (t.Bits - 1), 1<<(t.Bits-1) - 1
+}
+
+// truncate wraps v around to the range of t
+func (t BitFieldType) truncate(v uint64) int64 {
+	if t.Bits == 64 {
+		return int64(v)
+	}
+	v &= 1<<t.Bits - 1
+	if t.Signed && v&(1<<(t.Bits-1)) != 0 {
+		v |= ^uint64(0) << t.Bits
+	}
+	return int64(v)
+}
+
+// get reads an integer of type t at the bit offset
+func (t BitFieldType) get(b []byte, offset uint64) int64 {
+	var v uint64
+	for i := uint64(0); i < uint64(t.Bits); i++ {
+		v = v<<1 | uint64(getBit(b, offset+i))
+	}
+	return t.truncate(v)
+}
+
+// set writes v, which must be in the range of t, at the bit offset, within b
+func (t BitFieldType) set(b []byte, offset uint64, v int64) {
+	for i := uint64(0); i < uint64(t.Bits); i++ {
+		setBit(b, offset+i, int(uint64(v)>>(uint64(t.Bits)-1-i))&1)
+	}
+}
+
+// BitFieldOverflow is the behavior of BitField when a value doesn't fit in its type
+type BitFieldOverflow int
+
+const (
+	// OverflowWrap wraps around, like integer arithmetic in Go
+	OverflowWrap BitFieldOverflow = iota
+	// OverflowSat saturates to the smallest or largest value of the type
+	OverflowSat
+	// OverflowFail leaves the value unchanged and returns no result
+	OverflowFail
+)
+
+// BitFieldOpType is the kind of a BitFieldOp
+type BitFieldOpType int
+
+const (
+	BitFieldGet BitFieldOpType = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// BitFieldOp is an operation of BitField on an integer stored in a bitmap
+type BitFieldOp struct {
+	Op   BitFieldOpType
+	Type BitFieldType
+	// Offset is in bits, or in multiples of the width of Type if Scaled is set, like "#n" in Redis
+	Offset int64
+	Scaled bool
+	// Value is the value to set or the increment
+	Value    int64
+	Overflow BitFieldOverflow
+}
+
+// maxBitOffset bounds the bit offsets of BitField, so that the end of an integer
+// and the number of bytes it spans can't overflow
+const maxBitOffset = math.MaxInt64
+
+// offset returns the offset of op in bits
+func (op BitFieldOp) offset() (uint64, error) {
+	if op.Offset < 0 {
+		return 0, ErrOffsetOutOfRange
+	}
+	offset := uint64(op.Offset)
+	if op.Scaled {
+		if offset > maxBitOffset/uint64(op.Type.Bits) {
+			return 0, ErrOffsetOutOfRange
+		}
+		offset *= uint64(op.Type.Bits)
+	}
+	if offset > maxBitOffset-uint64(op.Type.Bits) {
+		return 0, ErrOffsetOutOfRange
+	}
+	return offset, nil
+}
+
+// apply computes the new value of a SET or INCRBY whose old value is old.
+// It returns false if the result overflows with OverflowFail.
+func (op BitFieldOp) apply(old int64) (int64, bool) {
+	min, max := op.Type.bounds()
+	var wrapped uint64
+	var up, down bool
+	if op.Op == BitFieldSet {
+		wrapped = uint64(op.Value)
+		up, down = op.Value > max, op.Value < min
+	} else {
+		wrapped = uint64(old) + uint64(op.Value)
+		up = op.Value > 0 && old > max-op.Value
+		down = op.Value < 0 && (op.Value == math.MinInt64 && !op.Type.Signed || old < min-op.Value)
+	}
+
+	switch {
+	case !up && !down:
+		return int64(wrapped), true
+	case op.Overflow == OverflowFail:
+		return old, false
+	case op.Overflow == OverflowSat && up:
+		return max, true
+	case op.Overflow == OverflowSat:
+		return min, true
+	default:
+		return op.Type.truncate(wrapped), true
+	}
+}
@@ -0,0 +1,127 @@
+package mycache
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/RGBli/MyCache/util"
+)
+
+// Parameters of the Bloom filters created implicitly by BFAdd and BFMAdd, like in RedisBloom
+var (
+	// BloomDefaultErrorRate is the default rate of false positives
+	BloomDefaultErrorRate = 0.01
+	// BloomDefaultCapacity is the default number of items the rate of false positives is computed for
+	BloomDefaultCapacity = 100
+)
+
+// Bloom is a Bloom filter: a set of items that may report items as present when they aren't,
+// at the error rate it was created with while it holds at most its capacity.
+type Bloom struct {
+	filter    *util.BloomFilter
+	errorRate float64
+	capacity  int
+}
+
+// NewBloom returns a Bloom filter sized for capacity items with the given rate of false positives.
+// It returns ErrInvalidErrorRate or ErrInvalidCapacity if they are out of range.
+func NewBloom(errorRate float64, capacity int) (*Bloom, error) {
+	bits, hashes, err := bloomParameters(errorRate, capacity)
+	if err != nil {
+		return nil, err
+	}
+	return &Bloom{
+		filter:    util.NewBloomFilter(hashes, bits),
+		errorRate: errorRate,
+		capacity:  capacity,
+	}, nil
+}
+
+// bloomParameters returns the number of bits and of hash functions of NewBloom
+func bloomParameters(errorRate float64, capacity int) (int, int, error) {
+	if !(errorRate > 0 && errorRate < 1) {
+		return 0, 0, ErrInvalidErrorRate
+	}
+	if capacity <= 0 {
+		return 0, 0, ErrInvalidCapacity
+	}
+	bits, hashes := util.EstimateParameters(capacity, errorRate)
+	if bits > util.MaxBloomFilterSize {
+		return 0, 0, ErrInvalidCapacity
+	}
+	return bits, hashes, nil
+}
+
+// bloomSize returns the size of a Bloom filter created by NewBloom, without allocating it
+func bloomSize(errorRate float64, capacity int) (uint64, error) {
+	bits, _, err := bloomParameters(errorRate, capacity)
+	if err != nil {
+		return 0, err
+	}
+	return uint64((bits + 63) / 64 * 8), nil
+}
+
+func (b *Bloom) Size() uint64 {
+	return uint64(b.filter.MemSize())
+}
+
+// Len returns the number of items added, not counting those that were reported present
+func (b *Bloom) Len() int {
+	return b.filter.GetEleNum()
+}
+
+func (b *Bloom) Type() string {
+	return "Bloom"
+}
+
+// ErrorRate returns the rate of false positives the filter was created with
+func (b *Bloom) ErrorRate() float64 {
+	return b.errorRate
+}
+
+// Capacity returns the number of items the filter was created for
+func (b *Bloom) Capacity() int {
+	return b.capacity
+}
+
+// Add adds item, and returns false if it may have been added before
+func (b *Bloom) Add(item string) bool {
+	return !b.filter.TestAndAdd([]byte(item))
+}
+
+// Exists returns false if item was never added, and true if it probably was
+func (b *Bloom) Exists(item string) bool {
+	return b.filter.Contains([]byte(item))
+}
+
+// MarshalBinary encodes the filter as its error rate in 8 bytes, its capacity as a uvarint,
+// and the encoding of its util.BloomFilter
+func (b *Bloom) MarshalBinary() ([]byte, error) {
+	filter, err := b.filter.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 0, 8+binary.MaxVarintLen64+len(filter))
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(b.errorRate))
+	data = binary.AppendUvarint(data, uint64(b.capacity))
+	return append(data, filter...), nil
+}
+
+// UnmarshalBinary decodes data produced by MarshalBinary, replacing the content of the filter.
+// It returns util.ErrInvalidBloomFilter if data is malformed.
+func (b *Bloom) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return util.ErrInvalidBloomFilter
+	}
+	errorRate := math.Float64frombits(binary.LittleEndian.Uint64(data))
+	capacity, n := binary.Uvarint(data[8:])
+	if n <= 0 || !(errorRate > 0 && errorRate < 1) || capacity == 0 || capacity > math.MaxInt32 {
+		return util.ErrInvalidBloomFilter
+	}
+	filter := new(util.BloomFilter)
+	if err := filter.UnmarshalBinary(data[8+n:]); err != nil {
+		return err
+	}
+	*b = Bloom{filter: filter, errorRate: errorRate, capacity: int(capacity)}
+	return nil
+}
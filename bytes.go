@@ -0,0 +1,28 @@
+package mycache
+
+// Bytes is a binary-safe value holding a byte slice
+type Bytes struct {
+	b []byte
+}
+
+// NewBytes returns a Bytes value holding b, which must not be modified afterwards
+func NewBytes(b []byte) *Bytes {
+	return &Bytes{b: b}
+}
+
+func (b *Bytes) Size() uint64 {
+	return uint64(len(b.b))
+}
+
+func (b *Bytes) Len() int {
+	return len(b.b)
+}
+
+func (b *Bytes) Type() string {
+	return "Bytes"
+}
+
+// ToBytes returns the underlying slice, which must not be modified
+func (b *Bytes) ToBytes() []byte {
+	return b.b
+}
@@ -0,0 +1,208 @@
+package mycache
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// maxSketchCounters bounds the number of counters of a sketch, so that its size can't overflow
+const maxSketchCounters = math.MaxInt32
+
+// CountMinSketch estimates the frequencies of items in a table of depth rows of width counters.
+// An estimate is never below the true count, and exceeds it by at most 2/width of the total
+// count with probability 1 - 1/2^depth.
+type CountMinSketch struct {
+	width    int
+	depth    int
+	counters []uint64
+	// count is the total of the increments
+	count uint64
+}
+
+// NewCountMinSketch returns an empty CountMinSketch of depth rows of width counters.
+// It returns ErrInvalidDimensions if they aren't positive.
+func NewCountMinSketch(width, depth int) (*CountMinSketch, error) {
+	if err := checkSketchDimensions(width, depth); err != nil {
+		return nil, err
+	}
+	return &CountMinSketch{width: width, depth: depth, counters: make([]uint64, width*depth)}, nil
+}
+
+// NewCountMinSketchWithProb returns an empty CountMinSketch whose estimates exceed the true counts
+// by at most errorRate of the total count, with probability 1 - prob, like CMS.INITBYPROB in Redis
+func NewCountMinSketchWithProb(errorRate, prob float64) (*CountMinSketch, error) {
+	width, depth, err := countMinSketchDimensions(errorRate, prob)
+	if err != nil {
+		return nil, err
+	}
+	return NewCountMinSketch(width, depth)
+}
+
+// countMinSketchDimensions returns the width and depth of NewCountMinSketchWithProb
+func countMinSketchDimensions(errorRate, prob float64) (int, int, error) {
+	if !(errorRate > 0 && errorRate < 1) || !(prob > 0 && prob < 1) {
+		return 0, 0, ErrInvalidErrorRate
+	}
+	width := math.Ceil(2 / errorRate)
+	depth := math.Ceil(math.Log(prob) / math.Log(0.5))
+	if width*depth > maxSketchCounters {
+		return 0, 0, ErrInvalidDimensions
+	}
+	return int(width), int(depth), nil
+}
+
+// checkSketchDimensions returns ErrInvalidDimensions if a sketch can't have depth rows of width counters
+func checkSketchDimensions(width, depth int) error {
+	if width <= 0 || depth <= 0 || width > maxSketchCounters/depth {
+		return ErrInvalidDimensions
+	}
+	return nil
+}
+
+func (c *CountMinSketch) Size() uint64 {
+	return uint64(8 * len(c.counters))
+}
+
+// Len returns the total of the increments, capped at the largest int
+func (c *CountMinSketch) Len() int {
+	return int(min(c.count, math.MaxInt))
+}
+
+func (c *CountMinSketch) Type() string {
+	return "CountMinSketch"
+}
+
+// Width returns the number of counters of a row
+func (c *CountMinSketch) Width() int {
+	return c.width
+}
+
+// Depth returns the number of rows
+func (c *CountMinSketch) Depth() int {
+	return c.depth
+}
+
+// Count returns the total of the increments
+func (c *CountMinSketch) Count() uint64 {
+	return c.count
+}
+
+// index returns the position in counters of the counter of item in row i
+func (c *CountMinSketch) index(h uint64, i int) int {
+	h1, h2 := uint32(h), uint32(h>>32)
+	return i*c.width + int((h1+uint32(i)*h2)%uint32(c.width))
+}
+
+// IncrBy increments the count of item by incr, saturating at the largest uint64,
+// and returns its new estimated count
+func (c *CountMinSketch) IncrBy(item string, incr uint64) uint64 {
+	h := murmurHash64A([]byte(item), 0)
+	est := uint64(math.MaxUint64)
+	for i := 0; i < c.depth; i++ {
+		j := c.index(h, i)
+		c.counters[j] = saturatingAdd(c.counters[j], incr)
+		est = min(est, c.counters[j])
+	}
+	c.count = saturatingAdd(c.count, incr)
+	return est
+}
+
+// Query returns the estimated count of item
+func (c *CountMinSketch) Query(item string) uint64 {
+	h := murmurHash64A([]byte(item), 0)
+	est := uint64(math.MaxUint64)
+	for i := 0; i < c.depth; i++ {
+		est = min(est, c.counters[c.index(h, i)])
+	}
+	return est
+}
+
+// Merge adds the counters of others, multiplied by the corresponding weights, to those of c.
+// weights may be nil, in which case all weights are 1.
+// It returns ErrInvalidWeights if there are not as many weights as sketches,
+// and ErrIncompatibleSketch if a sketch has other dimensions than c, leaving c unchanged.
+func (c *CountMinSketch) Merge(others []*CountMinSketch, weights []uint64) error {
+	if weights != nil && len(weights) != len(others) {
+		return ErrInvalidWeights
+	}
+	for _, o := range others {
+		if o.width != c.width || o.depth != c.depth {
+			return ErrIncompatibleSketch
+		}
+	}
+
+	for k, o := range others {
+		weight := uint64(1)
+		if weights != nil {
+			weight = weights[k]
+		}
+		for j, n := range o.counters {
+			c.counters[j] = saturatingAdd(c.counters[j], saturatingMul(n, weight))
+		}
+		c.count = saturatingAdd(c.count, saturatingMul(o.count, weight))
+	}
+	return nil
+}
+
+// Clone returns a copy of c
+func (c *CountMinSketch) Clone() *CountMinSketch {
+	clone := *c
+	clone.counters = append([]uint64(nil), c.counters...)
+	return &clone
+}
+
+// MarshalBinary encodes the sketch as its width, depth and count as uvarints,
+// followed by its counters in 8 bytes each
+func (c *CountMinSketch) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, 3*binary.MaxVarintLen64+8*len(c.counters))
+	data = binary.AppendUvarint(data, uint64(c.width))
+	data = binary.AppendUvarint(data, uint64(c.depth))
+	data = binary.AppendUvarint(data, c.count)
+	for _, n := range c.counters {
+		data = binary.LittleEndian.AppendUint64(data, n)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes data produced by MarshalBinary, replacing the content of the sketch.
+// It returns ErrInvalidEncoding if data is malformed.
+func (c *CountMinSketch) UnmarshalBinary(data []byte) error {
+	header, data, ok := readUvarints(data, 3)
+	if !ok || header[0] == 0 || header[1] == 0 || header[0] > maxSketchCounters/header[1] ||
+		uint64(len(data)) != 8*header[0]*header[1] {
+		return ErrInvalidEncoding
+	}
+	counters := make([]uint64, header[0]*header[1])
+	for j := range counters {
+		counters[j] = binary.LittleEndian.Uint64(data[8*j:])
+	}
+	*c = CountMinSketch{width: int(header[0]), depth: int(header[1]), counters: counters, count: header[2]}
+	return nil
+}
+
+// readUvarints decodes n uvarints at the start of data, and returns them with the rest of data
+func readUvarints(data []byte, n int) ([]uint64, []byte, bool) {
+	values := make([]uint64, n)
+	for i := range values {
+		v, size := binary.Uvarint(data)
+		if size <= 0 {
+			return nil, nil, false
+		}
+		values[i], data = v, data[size:]
+	}
+	return values, data, true
+}
+
+func saturatingAdd(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
+func saturatingMul(a, b uint64) uint64 {
+	if a != 0 && b > math.MaxUint64/a {
+		return math.MaxUint64
+	}
+	return a * b
+}
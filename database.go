@@ -2,6 +2,8 @@ package mycache
 
 import (
 	"container/list"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -13,6 +15,13 @@ type database struct {
 	size    uint64
 	cache   map[string]*list.Element
 	list    *list.List
+	// waiters holds the goroutines blocked on each list key, in FIFO order
+	waiters map[string]*list.List
+	// guard knows the keys that exist in the backend, see Load
+	guard Guard
+	// loads holds the calls of loaders in progress, under loadMu
+	loadMu sync.Mutex
+	loads  map[string]*loadCall
 }
 
 // entry is the data stored in list.
@@ -26,125 +35,257 @@ func isExpire(e *list.Element) bool {
 	return !e.Value.(*entry).expireTime.IsZero() && e.Value.(*entry).expireTime.Before(time.Now())
 }
 
-// get returns the entry for given key.
-func (db *database) get(key string) (Valuer, bool) {
+// lookup returns the value for given key, or ErrNotFound or ErrExpired if there is no live entry.
+func (db *database) lookup(key string) (Valuer, error) {
 	e := db.cache[key]
 	if e == nil {
-		return nil, false
+		return nil, ErrNotFound
 	}
 
 	// only get alive entry
 	if isExpire(e) {
 		db.remove(key)
-		return nil, false
+		return nil, ErrExpired
 	}
 
 	db.list.MoveToFront(e)
-	return e.Value.(*entry).value, true
+	return e.Value.(*entry).value, nil
+}
+
+// get returns the value for given key.
+func (db *database) get(key string) (Valuer, bool) {
+	v, err := db.lookup(key)
+	return v, err == nil
+}
+
+// getString returns the String stored under key, or nil if the key doesn't exist.
+func (db *database) getString(key string) (*String, error) {
+	v, ok := db.get(key)
+	if !ok {
+		return nil, nil
+	}
+	s, ok := v.(*String)
+	if !ok {
+		return nil, wrongType(key, "String", v)
+	}
+	return s, nil
+}
+
+// getList returns the List stored under key, or nil if the key doesn't exist.
+func (db *database) getList(key string) (*List, error) {
+	v, ok := db.get(key)
+	if !ok {
+		return nil, nil
+	}
+	l, ok := v.(*List)
+	if !ok {
+		return nil, wrongType(key, "List", v)
+	}
+	return l, nil
+}
+
+// getQueue returns the Queue stored under key, or nil if the key doesn't exist.
+func (db *database) getQueue(key string) (*Queue, error) {
+	v, ok := db.get(key)
+	if !ok {
+		return nil, nil
+	}
+	q, ok := v.(*Queue)
+	if !ok {
+		return nil, wrongType(key, "Queue", v)
+	}
+	return q, nil
+}
+
+// getHash returns the Hash stored under key, or nil if the key doesn't exist.
+// It removes the expired fields first.
+func (db *database) getHash(key string) (*Hash, error) {
+	v, ok := db.get(key)
+	if !ok {
+		return nil, nil
+	}
+	h, ok := v.(*Hash)
+	if !ok {
+		return nil, wrongType(key, "Hash", v)
+	}
+	if db.expireFields(key, h, time.Now()) {
+		return nil, nil
+	}
+	return h, nil
+}
+
+// getSet returns the Set stored under key, or nil if the key doesn't exist.
+func (db *database) getSet(key string) (*Set, error) {
+	v, ok := db.get(key)
+	if !ok {
+		return nil, nil
+	}
+	set, ok := v.(*Set)
+	if !ok {
+		return nil, wrongType(key, "Set", v)
+	}
+	return set, nil
+}
+
+// getZset returns the Zset stored under key, or nil if the key doesn't exist.
+func (db *database) getZset(key string) (*Zset, error) {
+	v, ok := db.get(key)
+	if !ok {
+		return nil, nil
+	}
+	zset, ok := v.(*Zset)
+	if !ok {
+		return nil, wrongType(key, "Zset", v)
+	}
+	return zset, nil
 }
 
 // Get get valuer from database
 func (db *database) Get(key string) (Valuer, bool) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	v, err := db.Fetch(key)
+	return v, err == nil
+}
 
-	return db.get(key)
+// Fetch is like Get, but returns ErrNotFound or ErrExpired if there is no live entry for key
+func (db *database) Fetch(key string) (Valuer, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.lookup(key)
 }
 
 // GetString get value from string
 func (db *database) GetString(key string) (*String, bool) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	s, err := db.FetchString(key)
+	return s, err == nil
+}
 
-	v, ok := db.get(key)
-	if !ok {
-		return nil, false
+// FetchString is like GetString, but returns ErrNotFound, ErrExpired or a WrongTypeError
+// instead of false
+func (db *database) FetchString(key string) (*String, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	v, err := db.lookup(key)
+	if err != nil {
+		return nil, err
 	}
 
 	s, ok := v.(*String)
 	if !ok {
-		return nil, false
+		return nil, wrongType(key, "String", v)
 	}
 
-	return s, true
+	return s, nil
 }
 
 // GetList get value from list
 func (db *database) GetList(key string) (*List, bool) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	l, err := db.FetchList(key)
+	return l, err == nil
+}
 
-	v, ok := db.get(key)
-	if !ok {
-		return nil, false
+// FetchList is like GetList, but returns ErrNotFound, ErrExpired or a WrongTypeError
+// instead of false
+func (db *database) FetchList(key string) (*List, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	v, err := db.lookup(key)
+	if err != nil {
+		return nil, err
 	}
 
 	l, ok := v.(*List)
 	if !ok {
-		return nil, false
+		return nil, wrongType(key, "List", v)
 	}
 
-	return l, true
+	return l, nil
 }
 
 // GetHash get value from hash
 func (db *database) GetHash(key string) (*Hash, bool) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	h, err := db.FetchHash(key)
+	return h, err == nil
+}
 
-	v, ok := db.get(key)
-	if !ok {
-		return nil, false
+// FetchHash is like GetHash, but returns ErrNotFound, ErrExpired or a WrongTypeError
+// instead of false. Like the hash commands, it removes the expired fields first.
+func (db *database) FetchHash(key string) (*Hash, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	v, err := db.lookup(key)
+	if err != nil {
+		return nil, err
 	}
 
 	h, ok := v.(*Hash)
 	if !ok {
-		return nil, false
+		return nil, wrongType(key, "Hash", v)
+	}
+	if db.expireFields(key, h, time.Now()) {
+		return nil, ErrNotFound
 	}
 
-	return h, true
+	return h, nil
 }
 
 // GetSet get value from set
 func (db *database) GetSet(key string) (*Set, bool) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	set, err := db.FetchSet(key)
+	return set, err == nil
+}
 
-	v, ok := db.get(key)
-	if !ok {
-		return nil, false
+// FetchSet is like GetSet, but returns ErrNotFound, ErrExpired or a WrongTypeError
+// instead of false
+func (db *database) FetchSet(key string) (*Set, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	v, err := db.lookup(key)
+	if err != nil {
+		return nil, err
 	}
 
 	set, ok := v.(*Set)
 	if !ok {
-		return nil, false
+		return nil, wrongType(key, "Set", v)
 	}
 
-	return set, true
+	return set, nil
 }
 
 // GetZset get value from zset
 func (db *database) GetZset(key string) (*Zset, bool) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	zset, err := db.FetchZset(key)
+	return zset, err == nil
+}
 
-	v, ok := db.get(key)
-	if !ok {
-		return nil, false
+// FetchZset is like GetZset, but returns ErrNotFound, ErrExpired or a WrongTypeError
+// instead of false
+func (db *database) FetchZset(key string) (*Zset, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	v, err := db.lookup(key)
+	if err != nil {
+		return nil, err
 	}
 
 	zset, ok := v.(*Zset)
 	if !ok {
-		return nil, false
+		return nil, wrongType(key, "Zset", v)
 	}
 
-	return zset, true
+	return zset, nil
 }
 
 // GetExpireTime returns the expire time and whether this entry exists in cache
 func (db *database) GetExpireTime(key string) (time.Time, bool) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	db.mu.Lock()
+	defer db.mu.Unlock()
 
 	e, ok := db.cache[key]
 	if !ok || isExpire(e) {
@@ -156,28 +297,173 @@ func (db *database) GetExpireTime(key string) (time.Time, bool) {
 }
 
 // SetValue stores entry for given key.
-func (db *database) SetValue(key string, value Valuer) {
+// It returns ErrOutOfMemory if the value alone doesn't fit in the cache capacity.
+func (db *database) SetValue(key string, value Valuer) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	if e, ok := db.cache[key]; ok && !isExpire(e) {
-		db.list.MoveToFront(e)
-		e.Value.(*entry).value = value
-		db.size += value.Size() - e.Value.(*entry).value.Size()
-	} else {
-		if ok && isExpire(e) {
-			db.remove(key)
+	if err := db.set(key, value); err != nil {
+		return err
+	}
+	db.signal(key)
+	return nil
+}
+
+// set stores value for key without locking. The expire time of a live entry is kept.
+func (db *database) set(key string, value Valuer) error {
+	_, err := db.store(key, value)
+	return err
+}
+
+// setWithExpireTime stores value for key without locking, replacing its expire time.
+func (db *database) setWithExpireTime(key string, value Valuer, expireTime time.Time) error {
+	en, err := db.store(key, value)
+	if err != nil {
+		return err
+	}
+	en.expireTime = expireTime
+	return nil
+}
+
+// overwrite stores the result of a *STORE command in key, discarding its expire time,
+// or deletes key if the result is empty.
+func (db *database) overwrite(key string, value Valuer) error {
+	if value.Len() == 0 {
+		db.remove(key)
+		return nil
+	}
+	return db.setWithExpireTime(key, value, time.Time{})
+}
+
+// checkSize returns ErrOutOfMemory if a value of size bytes can't fit in the cache capacity,
+// so that commands can reject a value before allocating it
+func (db *database) checkSize(size uint64) error {
+	if size > db.mycache.capacity {
+		return ErrOutOfMemory
+	}
+	return nil
+}
+
+// reserve stores the value returned by newValue under key, unless key already exists.
+// It returns ErrOutOfMemory without calling newValue if size, the size of the value, is too large.
+func (db *database) reserve(key string, size uint64, newValue func() Valuer) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.get(key); ok {
+		return ErrKeyExists
+	}
+	if err := db.checkSize(size); err != nil {
+		return err
+	}
+	return db.set(key, newValue())
+}
+
+// getAll returns the values stored at keys without locking, using get,
+// or ErrNotFound if one of the keys doesn't exist
+func getAll[E any](db *database, keys []string, get func(db *database, key string) (*E, error)) ([]*E, error) {
+	vs := make([]*E, len(keys))
+	for i, key := range keys {
+		v, err := get(db, key)
+		if err != nil {
+			return nil, err
 		}
-		e := &entry{
-			key:   key,
-			value: value,
+		if v == nil {
+			return nil, ErrNotFound
+		}
+		vs[i] = v
+	}
+	return vs, nil
+}
+
+// mergeFrom calls merge under the lock of db with the values stored at keys in src, see getAll.
+// If src is another database, the values are cloned under its lock first,
+// so that both databases are never locked at once.
+func mergeFrom[E any](db, src *database, keys []string, get func(db *database, key string) (*E, error),
+	clone func(v *E) *E, merge func(srcs []*E) error) error {
+	if src != db {
+		src.mu.Lock()
+		srcs, err := getAll(src, keys, get)
+		for i, v := range srcs {
+			srcs[i] = clone(v)
+		}
+		src.mu.Unlock()
+		if err != nil {
+			return err
 		}
-		element := db.list.PushFront(e)
-		db.cache[key] = element
-		db.size += value.Size()
 
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		return merge(srcs)
 	}
 
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	srcs, err := getAll(db, keys, get)
+	if err != nil {
+		return err
+	}
+	return merge(srcs)
+}
+
+// store stores value for key and returns its entry, evicting other entries if needed.
+func (db *database) store(key string, value Valuer) (*entry, error) {
+	if err := db.checkSize(value.Size()); err != nil {
+		return nil, err
+	}
+
+	e, ok := db.cache[key]
+	if ok && !isExpire(e) {
+		db.list.MoveToFront(e)
+		en := e.Value.(*entry)
+		db.size += value.Size() - en.value.Size()
+		en.value = value
+		db.evict()
+		return en, nil
+	}
+
+	if ok {
+		db.remove(key)
+	}
+	en := &entry{
+		key:   key,
+		value: value,
+	}
+	db.cache[key] = db.list.PushFront(en)
+	if db.guard != nil {
+		db.guard.Add(key)
+	}
+	db.size += value.Size()
+	db.evict()
+	return en, nil
+}
+
+// update accounts for a value stored under key that was modified in place, oldSize being its
+// size before the modification, and evicts other entries if needed.
+// Commands check with checkSize that a value can grow before modifying it, so that a write that
+// doesn't fit is rejected with the value left intact. A value that outgrew the cache capacity anyway
+// can't be kept: key is removed and ErrOutOfMemory is returned.
+func (db *database) update(key string, value Valuer, oldSize uint64) error {
+	db.size += value.Size() - oldSize
+	if value.Size() > db.mycache.capacity {
+		db.remove(key)
+		return ErrOutOfMemory
+	}
+	db.evict()
+	return nil
+}
+
+// updateContainer accounts for a container stored under key that shrank in place,
+// and deletes key once the container is empty.
+func (db *database) updateContainer(key string, value Valuer, oldSize uint64) {
+	db.size -= oldSize - value.Size()
+	if value.Len() == 0 {
+		db.remove(key)
+	}
+}
+
+// evict removes least recently used entries until the database fits in capacity.
+func (db *database) evict() {
 	for db.size > db.mycache.capacity {
 		e := db.list.Back()
 		delete(db.cache, e.Value.(*entry).key)
@@ -201,36 +487,17 @@ func (db *database) SetExpireTime(key string, expireTime time.Time) {
 	}
 }
 
-// SetValueAndExpireTime sets or updates value and expire time for an entry
-func (db *database) SetValueAndExpireTime(key string, value Valuer, expireTime time.Time) {
+// SetValueAndExpireTime sets or updates value and expire time for an entry.
+// It returns ErrOutOfMemory if the value alone doesn't fit in the cache capacity.
+func (db *database) SetValueAndExpireTime(key string, value Valuer, expireTime time.Time) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	if e, ok := db.cache[key]; ok && !isExpire(e) {
-		db.list.MoveToFront(e)
-		e.Value.(*entry).value = value
-		e.Value.(*entry).expireTime = expireTime
-		db.size += value.Size() - e.Value.(*entry).value.Size()
-	} else {
-		if ok && isExpire(e) {
-			db.remove(key)
-		}
-		e := &entry{
-			key:        key,
-			value:      value,
-			expireTime: expireTime,
-		}
-		element := db.list.PushFront(e)
-		db.cache[key] = element
-		db.size += value.Size()
-	}
-
-	for db.size > db.mycache.capacity {
-		e := db.list.Back()
-		delete(db.cache, e.Value.(*entry).key)
-		db.list.Remove(e)
-		db.size -= e.Value.(*entry).value.Size()
+	if err := db.setWithExpireTime(key, value, expireTime); err != nil {
+		return err
 	}
+	db.signal(key)
+	return nil
 }
 
 // Remove deletes a single entry with lock
@@ -252,16 +519,31 @@ func (db *database) remove(key string) {
 	db.size -= e.Value.(*entry).value.Size()
 }
 
-// RemoveExpired deletes all the expired entries
-func (db *database) RemoveExpired() {
+// RemoveExpired deletes all the expired entries and hash fields,
+// and returns timed out messages to their queues.
+// It returns the errors of the queues whose dead messages couldn't be moved to their dead letter list;
+// the messages of these queues stay in flight until the next call.
+func (db *database) RemoveExpired() error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	now := time.Now()
+	var errs []error
 	for key, e := range db.cache {
 		if isExpire(e) {
 			db.remove(key)
+			continue
+		}
+		switch v := e.Value.(*entry).value.(type) {
+		case *Queue:
+			if err := db.requeue(key, v, now); err != nil {
+				errs = append(errs, fmt.Errorf("requeue %s: %w", key, err))
+			}
+		case *Hash:
+			db.expireFields(key, v, now)
 		}
 	}
+	return errors.Join(errs...)
 }
 
 // Flush deletes all the entries
@@ -288,3 +570,24 @@ func (db *database) getSize() uint64 {
 	db.RemoveExpired()
 	return db.size
 }
+
+// normalizeRange converts the inclusive range [start, stop] over n elements, where negative
+// indexes count from the end, to a half-open range [lo, hi). ok is false if the range is empty.
+func normalizeRange(start, stop, n int) (lo, hi int, ok bool) {
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop || start >= n {
+		return 0, 0, false
+	}
+	return start, stop + 1, true
+}
@@ -0,0 +1,247 @@
+package mycache
+
+import "unsafe"
+
+// getBitmap returns the content of the Bytes or String stored under key without locking or copying it,
+// or nil if the key doesn't exist. The content must not be modified, see writeBitmap.
+func (db *database) getBitmap(key string) ([]byte, error) {
+	v, ok := db.get(key)
+	if !ok {
+		return nil, nil
+	}
+	switch v := v.(type) {
+	case *Bytes:
+		return v.b, nil
+	case *String:
+		return unsafe.Slice(unsafe.StringData(v.s), len(v.s)), nil
+	default:
+		return nil, wrongType(key, "Bytes", v)
+	}
+}
+
+// writeBitmap calls fn without locking with the bitmap stored under key, grown to at least n bytes.
+// A missing key is created as Bytes. A String stays a String: fn modifies a copy of it,
+// which then replaces its content.
+// It returns ErrOutOfMemory before growing the bitmap past the cache capacity.
+func (db *database) writeBitmap(key string, n uint64, fn func(b []byte)) error {
+	v, _ := db.get(key)
+	switch v := v.(type) {
+	case nil:
+		if err := db.checkSize(n); err != nil {
+			return err
+		}
+		b := NewBytes(make([]byte, n))
+		fn(b.b)
+		return db.set(key, b)
+	case *Bytes:
+		if n > uint64(len(v.b)) {
+			if err := db.checkSize(n); err != nil {
+				return err
+			}
+			oldSize := v.Size()
+			v.b = append(v.b, make([]byte, n-uint64(len(v.b)))...)
+			if err := db.update(key, v, oldSize); err != nil {
+				return err
+			}
+		}
+		fn(v.b)
+		return nil
+	case *String:
+		if n > uint64(len(v.s)) {
+			if err := db.checkSize(n); err != nil {
+				return err
+			}
+		}
+		b := make([]byte, max(n, uint64(len(v.s))))
+		copy(b, v.s)
+		fn(b)
+		return db.setString(key, v, string(b))
+	default:
+		return wrongType(key, "Bytes", v)
+	}
+}
+
+// SetBit sets the bit at offset in the bitmap stored at key to value, 0 or 1, growing it
+// as needed, and returns the old value of the bit
+func (db *database) SetBit(key string, offset uint64, value int) (int, error) {
+	if value != 0 && value != 1 {
+		return 0, ErrInvalidBit
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var old int
+	err := db.writeBitmap(key, offset/8+1, func(b []byte) {
+		old = setBit(b, offset, value)
+	})
+	return old, err
+}
+
+// GetBit returns the bit at offset in the bitmap stored at key, which is 0 past its end
+func (db *database) GetBit(key string, offset uint64) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	b, err := db.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+	return getBit(b, offset), nil
+}
+
+// BitCount returns the number of bits set in the bitmap stored at key
+func (db *database) BitCount(key string) (int, error) {
+	return db.BitCountRange(key, 0, -1, UnitByte)
+}
+
+// BitCountRange returns the number of bits set in the bitmap stored at key between start and end,
+// both inclusive, in bytes or bits. Negative offsets count from the end.
+func (db *database) BitCountRange(key string, start, end int, unit BitUnit) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	b, err := db.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+	lo, hi, ok := bitRange(len(b), start, end, unit)
+	if !ok {
+		return 0, nil
+	}
+	return countBits(b, lo, hi), nil
+}
+
+// BitPos returns the offset of the first bit set to bit, 0 or 1, in the bitmap stored at key,
+// or -1 if there is none. Like in Redis, the bitmap is considered padded with zero bits
+// when looking for a 0.
+func (db *database) BitPos(key string, bit int) (int64, error) {
+	return db.bitPos(key, bit, 0, -1, UnitByte, false)
+}
+
+// BitPosRange is like BitPos, but only looks between start and end, both inclusive,
+// in bytes or bits. It returns -1 if there is no such bit in the range.
+func (db *database) BitPosRange(key string, bit int, start, end int, unit BitUnit) (int64, error) {
+	return db.bitPos(key, bit, start, end, unit, true)
+}
+
+func (db *database) bitPos(key string, bit int, start, end int, unit BitUnit, bounded bool) (int64, error) {
+	if bit != 0 && bit != 1 {
+		return 0, ErrInvalidBit
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	b, err := db.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+	if b == nil {
+		if bit == 0 && !bounded {
+			return 0, nil
+		}
+		return -1, nil
+	}
+
+	lo, hi, ok := bitRange(len(b), start, end, unit)
+	if !ok {
+		return -1, nil
+	}
+	pos := findBit(b, bit, lo, hi)
+	if pos == -1 && bit == 0 && !bounded {
+		return int64(8 * len(b)), nil
+	}
+	return pos, nil
+}
+
+// BitOp stores in dst the result of op applied to the bitmaps stored at keys, where missing keys
+// are empty bitmaps, and returns its length in bytes. BitNot takes a single key.
+// dst is deleted if the result is empty.
+func (db *database) BitOp(op BitOperation, dst string, keys ...string) (int, error) {
+	if len(keys) == 0 {
+		return 0, ErrWrongNumberOfArgs
+	}
+	if op == BitNot && len(keys) != 1 {
+		return 0, ErrBitOpNotArity
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	srcs := make([][]byte, len(keys))
+	for i, key := range keys {
+		b, err := db.getBitmap(key)
+		if err != nil {
+			return 0, err
+		}
+		srcs[i] = b
+	}
+
+	res := NewBytes(bitOp(op, srcs))
+	if err := db.overwrite(dst, res); err != nil {
+		return 0, err
+	}
+	return res.Len(), nil
+}
+
+// BitField applies ops in order to the integers stored in the bitmap at key, and returns the result
+// of each: the value for BitFieldGet, the old value for BitFieldSet and the new value for BitFieldIncrBy.
+// The result is nil if an operation fails with OverflowFail. The bitmap grows as needed, but it isn't
+// created if all the operations are gets.
+func (db *database) BitField(key string, ops ...BitFieldOp) ([]*int64, error) {
+	n := uint64(0)
+	write := false
+	for _, op := range ops {
+		if err := op.Type.validate(); err != nil {
+			return nil, err
+		}
+		offset, err := op.offset()
+		if err != nil {
+			return nil, err
+		}
+		if op.Op != BitFieldGet {
+			write = true
+			n = max(n, (offset+uint64(op.Type.Bits)+7)/8)
+		}
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	res := make([]*int64, len(ops))
+	apply := func(b []byte) {
+		for i, op := range ops {
+			offset, _ := op.offset()
+			v := op.Type.get(b, offset)
+			if op.Op == BitFieldGet {
+				res[i] = &v
+				continue
+			}
+
+			next, ok := op.apply(v)
+			if !ok {
+				continue
+			}
+			op.Type.set(b, offset, next)
+			if op.Op == BitFieldSet {
+				res[i] = &v
+			} else {
+				res[i] = &next
+			}
+		}
+	}
+
+	if write {
+		if err := db.writeBitmap(key, n, apply); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+	b, err := db.getBitmap(key)
+	if err != nil {
+		return nil, err
+	}
+	apply(b)
+	return res, nil
+}
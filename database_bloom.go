@@ -0,0 +1,94 @@
+package mycache
+
+// getBloom returns the Bloom filter stored under key without locking, or nil if the key doesn't exist
+func (db *database) getBloom(key string) (*Bloom, error) {
+	v, ok := db.get(key)
+	if !ok {
+		return nil, nil
+	}
+	b, ok := v.(*Bloom)
+	if !ok {
+		return nil, wrongType(key, "Bloom", v)
+	}
+	return b, nil
+}
+
+// BFReserve creates an empty Bloom filter under key for capacity items with the given rate of false positives.
+// It returns ErrKeyExists if key already exists, and ErrOutOfMemory if the filter doesn't fit in the cache capacity.
+func (db *database) BFReserve(key string, errorRate float64, capacity int) error {
+	size, err := bloomSize(errorRate, capacity)
+	if err != nil {
+		return err
+	}
+	return db.reserve(key, size, func() Valuer {
+		b, _ := NewBloom(errorRate, capacity)
+		return b
+	})
+}
+
+// BFAdd adds item to the Bloom filter stored at key, creating it with BloomDefaultErrorRate
+// and BloomDefaultCapacity if needed, and returns false if item may have been added before
+func (db *database) BFAdd(key string, item string) (bool, error) {
+	added, err := db.BFMAdd(key, item)
+	if err != nil {
+		return false, err
+	}
+	return added[0], nil
+}
+
+// BFMAdd is like BFAdd for several items
+func (db *database) BFMAdd(key string, items ...string) ([]bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	b, err := db.getBloom(key)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		size, err := bloomSize(BloomDefaultErrorRate, BloomDefaultCapacity)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.checkSize(size); err != nil {
+			return nil, err
+		}
+		b, _ = NewBloom(BloomDefaultErrorRate, BloomDefaultCapacity)
+		if err := db.set(key, b); err != nil {
+			return nil, err
+		}
+	}
+
+	added := make([]bool, len(items))
+	for i, item := range items {
+		added[i] = b.Add(item)
+	}
+	return added, nil
+}
+
+// BFExists returns whether item was probably added to the Bloom filter stored at key
+func (db *database) BFExists(key string, item string) (bool, error) {
+	exists, err := db.BFMExists(key, item)
+	if err != nil {
+		return false, err
+	}
+	return exists[0], nil
+}
+
+// BFMExists is like BFExists for several items
+func (db *database) BFMExists(key string, items ...string) ([]bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	b, err := db.getBloom(key)
+	if err != nil {
+		return nil, err
+	}
+	exists := make([]bool, len(items))
+	if b != nil {
+		for i, item := range items {
+			exists[i] = b.Exists(item)
+		}
+	}
+	return exists, nil
+}
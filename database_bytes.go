@@ -0,0 +1,49 @@
+package mycache
+
+import "unsafe"
+
+// SetBytes stores b under key without copying it. The database takes ownership of b,
+// which must not be modified afterwards.
+// It returns ErrOutOfMemory if the value alone doesn't fit in the cache capacity.
+func (db *database) SetBytes(key string, b []byte) error {
+	return db.SetValue(key, NewBytes(b))
+}
+
+// GetBytes returns a copy of the Bytes or String value stored under key
+func (db *database) GetBytes(key string) ([]byte, bool) {
+	b, err := db.FetchBytes(key)
+	return b, err == nil
+}
+
+// FetchBytes is like GetBytes, but returns ErrNotFound, ErrExpired or a WrongTypeError
+// instead of false
+func (db *database) FetchBytes(key string) ([]byte, error) {
+	var res []byte
+	err := db.ViewBytes(key, func(b []byte) {
+		res = make([]byte, len(b))
+		copy(res, b)
+	})
+	return res, err
+}
+
+// ViewBytes calls fn with the content of the Bytes or String value stored under key, without copying it.
+// fn runs with the database locked: it must not modify or retain b, nor use the database.
+// It returns ErrNotFound, ErrExpired or a WrongTypeError if there is no such value.
+func (db *database) ViewBytes(key string, fn func(b []byte)) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	v, err := db.lookup(key)
+	if err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case *Bytes:
+		fn(v.b)
+	case *String:
+		fn(unsafe.Slice(unsafe.StringData(v.s), len(v.s)))
+	default:
+		return wrongType(key, "Bytes", v)
+	}
+	return nil
+}
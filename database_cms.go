@@ -0,0 +1,94 @@
+package mycache
+
+// getCountMinSketch returns the CountMinSketch stored under key without locking,
+// or nil if the key doesn't exist
+func (db *database) getCountMinSketch(key string) (*CountMinSketch, error) {
+	v, ok := db.get(key)
+	if !ok {
+		return nil, nil
+	}
+	c, ok := v.(*CountMinSketch)
+	if !ok {
+		return nil, wrongType(key, "CountMinSketch", v)
+	}
+	return c, nil
+}
+
+// CMSInitByDim creates an empty CountMinSketch under key with depth rows of width counters.
+// It returns ErrKeyExists if key already exists.
+func (db *database) CMSInitByDim(key string, width, depth int) error {
+	if err := checkSketchDimensions(width, depth); err != nil {
+		return err
+	}
+	return db.reserve(key, uint64(8*width*depth), func() Valuer {
+		c, _ := NewCountMinSketch(width, depth)
+		return c
+	})
+}
+
+// CMSInitByProb creates an empty CountMinSketch under key whose estimates exceed the true counts
+// by at most errorRate of the total count, with probability 1 - prob.
+// It returns ErrKeyExists if key already exists.
+func (db *database) CMSInitByProb(key string, errorRate, prob float64) error {
+	width, depth, err := countMinSketchDimensions(errorRate, prob)
+	if err != nil {
+		return err
+	}
+	return db.CMSInitByDim(key, width, depth)
+}
+
+// CMSIncrBy increments the count of item in the CountMinSketch stored at key by incr,
+// and returns its new estimated count
+func (db *database) CMSIncrBy(key string, item string, incr uint64) (uint64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	c, err := db.getCountMinSketch(key)
+	if err != nil {
+		return 0, err
+	}
+	if c == nil {
+		return 0, ErrNotFound
+	}
+	return c.IncrBy(item, incr), nil
+}
+
+// CMSQuery returns the estimated counts of items in the CountMinSketch stored at key
+func (db *database) CMSQuery(key string, items ...string) ([]uint64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	c, err := db.getCountMinSketch(key)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrNotFound
+	}
+	counts := make([]uint64, len(items))
+	for i, item := range items {
+		counts[i] = c.Query(item)
+	}
+	return counts, nil
+}
+
+// CMSMerge adds to the CountMinSketch stored at dst the sketches stored at keys, multiplied by
+// the corresponding weights, which may be nil for weights of 1. See CountMinSketch.Merge.
+func (db *database) CMSMerge(dst string, keys []string, weights []uint64) error {
+	return db.CMSMergeFrom(db, dst, keys, weights)
+}
+
+// CMSMergeFrom is like CMSMerge, with the sketches stored at keys in the database src
+func (db *database) CMSMergeFrom(src *database, dst string, keys []string, weights []uint64) error {
+	return mergeFrom(db, src, keys, (*database).getCountMinSketch, (*CountMinSketch).Clone,
+		func(srcs []*CountMinSketch) error {
+			c, err := db.getCountMinSketch(dst)
+			if err != nil {
+				return err
+			}
+			if c == nil {
+				return ErrNotFound
+			}
+			return c.Merge(srcs, weights)
+		})
+}
@@ -0,0 +1,477 @@
+package mycache
+
+import "time"
+
+// hashOrCreate returns the Hash stored under key, storing an empty one if the key doesn't exist.
+func (db *database) hashOrCreate(key string) (*Hash, error) {
+	h, err := db.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		h = NewHash()
+		db.set(key, h)
+	}
+	return h, nil
+}
+
+// checkHashSize returns ErrOutOfMemory if h, stored at key, can't grow by n fields and values of bytes
+// total length within the cache capacity. h is then removed if it's empty, i.e. if it was just created.
+func (db *database) checkHashSize(key string, h *Hash, n int, bytes uint64) error {
+	if err := db.checkSize(h.maxSizeWith(n, bytes)); err != nil {
+		db.updateContainer(key, h, h.Size())
+		return err
+	}
+	return nil
+}
+
+// HSet sets field in the hash stored at key to value, and returns true if field is a new field
+func (db *database) HSet(key string, field, value string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.hashOrCreate(key)
+	if err != nil {
+		return false, err
+	}
+
+	if err := db.checkHashSize(key, h, 1, uint64(len(field)+len(value))); err != nil {
+		return false, err
+	}
+	oldSize := h.Size()
+	isNew := !h.Contains(field)
+	h.Put(field, value)
+	if err := db.update(key, h, oldSize); err != nil {
+		return false, err
+	}
+	return isNew, nil
+}
+
+// HSetNX sets field in the hash stored at key to value only if field doesn't exist yet,
+// and returns whether it was set
+func (db *database) HSetNX(key string, field, value string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.hashOrCreate(key)
+	if err != nil {
+		return false, err
+	}
+	if h.Contains(field) {
+		return false, nil
+	}
+	if err := db.checkHashSize(key, h, 1, uint64(len(field)+len(value))); err != nil {
+		return false, err
+	}
+
+	oldSize := h.Size()
+	h.Put(field, value)
+	if err := db.update(key, h, oldSize); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// HGet returns the value of field in the hash stored at key
+func (db *database) HGet(key string, field string) (string, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.getHash(key)
+	if err != nil || h == nil {
+		return "", false, err
+	}
+	v, ok := h.Get(field)
+	return v, ok, nil
+}
+
+// HMGet returns the values of fields in the hash stored at key.
+// The value of a field that doesn't exist is nil.
+func (db *database) HMGet(key string, fields ...string) ([]*string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]*string, len(fields))
+	if h == nil {
+		return values, nil
+	}
+	for i, field := range fields {
+		if v, ok := h.Get(field); ok {
+			values[i] = &v
+		}
+	}
+	return values, nil
+}
+
+// HGetAll returns all the fields and values of the hash stored at key
+func (db *database) HGetAll(key string) (map[string]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		return map[string]string{}, nil
+	}
+	return h.GetAll(), nil
+}
+
+// HDel removes fields from the hash stored at key and returns the number of removed fields
+func (db *database) HDel(key string, fields ...string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.getHash(key)
+	if err != nil || h == nil {
+		return 0, err
+	}
+
+	oldSize := h.Size()
+	n := 0
+	for _, field := range fields {
+		if h.Contains(field) {
+			h.Remove(field)
+			n++
+		}
+	}
+	db.updateContainer(key, h, oldSize)
+	return n, nil
+}
+
+// HExists returns whether field exists in the hash stored at key
+func (db *database) HExists(key string, field string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.getHash(key)
+	if err != nil || h == nil {
+		return false, err
+	}
+	return h.Contains(field), nil
+}
+
+// HLen returns the number of fields in the hash stored at key
+func (db *database) HLen(key string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.getHash(key)
+	if err != nil || h == nil {
+		return 0, err
+	}
+	return h.Len(), nil
+}
+
+// HIncrBy increments the integer stored in field of the hash stored at key by delta,
+// keeping the expire time of field. A missing field is treated as 0.
+func (db *database) HIncrBy(key string, field string, delta int64) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.hashOrCreate(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := db.checkHashSize(key, h, 1, uint64(len(field)+maxInt64Len)); err != nil {
+		return 0, err
+	}
+	oldSize := h.Size()
+	n, err := h.IncrBy(field, delta)
+	if err != nil {
+		// remove the hash if it was just created
+		db.updateContainer(key, h, oldSize)
+		return 0, err
+	}
+	if err := db.update(key, h, oldSize); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// HIncrByFloat increments the number stored in field of the hash stored at key by delta,
+// keeping the expire time of field. A missing field is treated as 0.
+// It returns ErrNotFloat if the value isn't a number, and ErrNaNOrInfinity if the result isn't finite.
+func (db *database) HIncrByFloat(key string, field string, delta float64) (float64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.hashOrCreate(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := db.checkHashSize(key, h, 1, uint64(len(field)+maxFloatLen)); err != nil {
+		return 0, err
+	}
+	oldSize := h.Size()
+	f, err := h.IncrByFloat(field, delta)
+	if err != nil {
+		db.updateContainer(key, h, oldSize)
+		return 0, err
+	}
+	if err := db.update(key, h, oldSize); err != nil {
+		return 0, err
+	}
+	return f, nil
+}
+
+// HMSet sets several fields in the hash stored at key at once, removing their expire times
+func (db *database) HMSet(key string, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.hashOrCreate(key)
+	if err != nil {
+		return err
+	}
+
+	var bytes uint64
+	for field, value := range values {
+		bytes += uint64(len(field) + len(value))
+	}
+	if err := db.checkHashSize(key, h, len(values), bytes); err != nil {
+		return err
+	}
+	oldSize := h.Size()
+	for field, value := range values {
+		h.Put(field, value)
+	}
+	return db.update(key, h, oldSize)
+}
+
+// HKeys returns the fields of the hash stored at key
+func (db *database) HKeys(key string) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		return []string{}, nil
+	}
+	return h.Keys(), nil
+}
+
+// HVals returns the values of the hash stored at key
+func (db *database) HVals(key string) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		return []string{}, nil
+	}
+	return h.Values(), nil
+}
+
+// HStrLen returns the length of the value of field in the hash stored at key
+func (db *database) HStrLen(key string, field string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.getHash(key)
+	if err != nil || h == nil {
+		return 0, err
+	}
+	return h.StrLen(field), nil
+}
+
+// HRandField returns random fields of the hash stored at key, see Hash.Random
+func (db *database) HRandField(key string, count int) ([]string, error) {
+	fields, err := db.HRandFieldWithValues(key, count)
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(fields))
+	for i, f := range fields {
+		strs[i] = f.Field
+	}
+	return strs, nil
+}
+
+// HRandFieldWithValues returns random fields of the hash stored at key with their values, see Hash.Random
+func (db *database) HRandFieldWithValues(key string, count int) ([]HashField, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.checkSampleCount(count); err != nil {
+		return nil, err
+	}
+	h, err := db.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		return []HashField{}, nil
+	}
+	return h.Random(count), nil
+}
+
+// HScan incrementally iterates over the fields of the hash stored at key, see Hash.Scan.
+// It returns the next cursor, which is 0 once the iteration is complete.
+func (db *database) HScan(key string, cursor uint64, match string, count int) (uint64, []HashField, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.getHash(key)
+	if err != nil || h == nil {
+		return 0, nil, err
+	}
+	next, fields := h.Scan(cursor, match, count)
+	return next, fields, nil
+}
+
+// Results of HExpire and HPersist for each field
+const (
+	// HFieldNotFound means that the field or the key doesn't exist
+	HFieldNotFound = -2
+	// HFieldNoExpire means that the field has no expire time
+	HFieldNoExpire = -1
+	// HFieldUpdated means that the expire time was set or removed
+	HFieldUpdated = 1
+	// HFieldDeleted means that the field was deleted because the expire time is in the past
+	HFieldDeleted = 2
+)
+
+// HExpire sets fields in the hash stored at key to expire after ttl, see HExpireAt.
+// ttl may have any precision, covering both HEXPIRE and HPEXPIRE.
+func (db *database) HExpire(key string, ttl time.Duration, fields ...string) ([]int, error) {
+	return db.HExpireAt(key, time.Now().Add(ttl), fields...)
+}
+
+// HExpireAt sets the expire time of fields in the hash stored at key. For each field, it returns
+// HFieldUpdated, HFieldNotFound, or HFieldDeleted if expireTime is in the past.
+// Setting a field with HSet or HSetNX removes its expire time.
+func (db *database) HExpireAt(key string, expireTime time.Time, fields ...string) ([]int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	res := make([]int, len(fields))
+	h, err := db.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		for i := range res {
+			res[i] = HFieldNotFound
+		}
+		return res, nil
+	}
+
+	if err := db.checkSize(h.Size() + uint64(expireEntrySize*len(fields))); err != nil {
+		return nil, err
+	}
+	oldSize := h.Size()
+	past := !expireTime.After(time.Now())
+	for i, field := range fields {
+		switch {
+		case !h.Contains(field):
+			res[i] = HFieldNotFound
+		case past:
+			h.Remove(field)
+			res[i] = HFieldDeleted
+		default:
+			h.SetExpireTime(field, expireTime)
+			res[i] = HFieldUpdated
+		}
+	}
+	if past {
+		db.updateContainer(key, h, oldSize)
+		return res, nil
+	}
+	return res, db.update(key, h, oldSize)
+}
+
+// HTTL returns the remaining time to live of fields in the hash stored at key.
+// For fields without an expire time it returns HFieldNoExpire, and for fields that don't exist
+// HFieldNotFound, as durations.
+func (db *database) HTTL(key string, fields ...string) ([]time.Duration, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	res := make([]time.Duration, len(fields))
+	for i, field := range fields {
+		if h == nil {
+			res[i] = HFieldNotFound
+			continue
+		}
+		expireTime, ok := h.ExpireTime(field)
+		switch {
+		case !ok:
+			res[i] = HFieldNotFound
+		case expireTime.IsZero():
+			res[i] = HFieldNoExpire
+		default:
+			res[i] = expireTime.Sub(now)
+		}
+	}
+	return res, nil
+}
+
+// HPersist removes the expire time of fields in the hash stored at key. For each field, it returns
+// HFieldUpdated, HFieldNoExpire or HFieldNotFound.
+func (db *database) HPersist(key string, fields ...string) ([]int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]int, len(fields))
+	if h == nil {
+		for i := range res {
+			res[i] = HFieldNotFound
+		}
+		return res, nil
+	}
+
+	oldSize := h.Size()
+	for i, field := range fields {
+		switch {
+		case !h.Contains(field):
+			res[i] = HFieldNotFound
+		case h.Persist(field):
+			res[i] = HFieldUpdated
+		default:
+			res[i] = HFieldNoExpire
+		}
+	}
+	db.updateContainer(key, h, oldSize)
+	return res, nil
+}
+
+// expireFields removes the fields of h, stored at key, expired at now without locking.
+// It deletes key and returns true if h became empty.
+func (db *database) expireFields(key string, h *Hash, now time.Time) bool {
+	oldSize := h.Size()
+	if h.RemoveExpired(now) == 0 {
+		return false
+	}
+	db.updateContainer(key, h, oldSize)
+	return h.Len() == 0
+}
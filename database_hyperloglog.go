@@ -0,0 +1,98 @@
+package mycache
+
+// getHyperLogLog returns the HyperLogLog stored under key without locking, or nil if the key doesn't exist
+func (db *database) getHyperLogLog(key string) (*HyperLogLog, error) {
+	v, ok := db.get(key)
+	if !ok {
+		return nil, nil
+	}
+	h, ok := v.(*HyperLogLog)
+	if !ok {
+		return nil, wrongType(key, "HyperLogLog", v)
+	}
+	return h, nil
+}
+
+// PFAdd adds elements to the HyperLogLog stored at key, creating it if needed,
+// and returns true if it was created or its estimated cardinality may have changed
+func (db *database) PFAdd(key string, elements ...string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	h, err := db.getHyperLogLog(key)
+	if err != nil {
+		return false, err
+	}
+	created := h == nil
+	if created {
+		h = NewHyperLogLog()
+		if err := db.set(key, h); err != nil {
+			return false, err
+		}
+	}
+
+	oldSize := h.Size()
+	changed := h.Add(elements...)
+	if err := db.update(key, h, oldSize); err != nil {
+		return false, err
+	}
+	return created || changed, nil
+}
+
+// PFCount returns the estimated number of distinct elements added to the HyperLogLogs stored at keys.
+// With several keys, it estimates the cardinality of their union.
+func (db *database) PFCount(keys ...string) (uint64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	hlls, err := db.getHyperLogLogs(keys)
+	if err != nil {
+		return 0, err
+	}
+	if len(hlls) == 1 {
+		return hlls[0].Count(), nil
+	}
+	union := NewHyperLogLog()
+	union.Merge(hlls...)
+	return union.Count(), nil
+}
+
+// PFMerge stores in dst the union of the HyperLogLogs stored at keys and at dst, if it exists
+func (db *database) PFMerge(dst string, keys ...string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	hlls, err := db.getHyperLogLogs(keys)
+	if err != nil {
+		return err
+	}
+	h, err := db.getHyperLogLog(dst)
+	if err != nil {
+		return err
+	}
+	if h == nil {
+		h = NewHyperLogLog()
+		if err := db.set(dst, h); err != nil {
+			return err
+		}
+	}
+
+	oldSize := h.Size()
+	h.Merge(hlls...)
+	return db.update(dst, h, oldSize)
+}
+
+// getHyperLogLogs returns the HyperLogLogs stored at keys, skipping the keys that don't exist
+func (db *database) getHyperLogLogs(keys []string) ([]*HyperLogLog, error) {
+	hlls := make([]*HyperLogLog, 0, len(keys))
+	for _, key := range keys {
+		h, err := db.getHyperLogLog(key)
+		if err != nil {
+			return nil, err
+		}
+		if h != nil {
+			hlls = append(hlls, h)
+		}
+	}
+	return hlls, nil
+}
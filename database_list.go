@@ -0,0 +1,423 @@
+package mycache
+
+import (
+	"container/list"
+	"context"
+)
+
+// LPush inserts values at the head of the list stored at key, creating the list if needed,
+// and returns the length of the list
+func (db *database) LPush(key string, values ...string) (int, error) {
+	return db.push(key, true, values)
+}
+
+// RPush appends values to the tail of the list stored at key, creating the list if needed,
+// and returns the length of the list
+func (db *database) RPush(key string, values ...string) (int, error) {
+	return db.push(key, false, values)
+}
+
+func (db *database) push(key string, front bool, values []string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	l, err := db.pushList(key, front, values)
+	if err != nil || l == nil {
+		return 0, err
+	}
+	// report the length before blocked clients take their elements
+	n := l.Len()
+	db.signal(key)
+	return n, nil
+}
+
+// pushList pushes values to the list stored at key without locking, creating the list if needed.
+// It returns nil if the key doesn't exist and there's nothing to push.
+func (db *database) pushList(key string, front bool, values []string) (*List, error) {
+	l, err := db.getList(key)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil && len(values) == 0 {
+		return nil, nil
+	}
+	size := uint64(0)
+	if l != nil {
+		size = l.Size()
+	}
+	for _, v := range values {
+		size += uint64(len(v))
+	}
+	if err := db.checkSize(size); err != nil {
+		return nil, err
+	}
+	if l == nil {
+		l = NewEmptyList()
+		db.set(key, l)
+	}
+
+	oldSize := l.Size()
+	for _, v := range values {
+		if front {
+			l.PushFront(v)
+		} else {
+			l.Add(v)
+		}
+	}
+	if err := db.update(key, l, oldSize); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// LPop removes and returns the first element of the list stored at key
+func (db *database) LPop(key string) (string, bool, error) {
+	return db.pop(key, true)
+}
+
+// RPop removes and returns the last element of the list stored at key
+func (db *database) RPop(key string) (string, bool, error) {
+	return db.pop(key, false)
+}
+
+func (db *database) pop(key string, front bool) (string, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	l, err := db.getList(key)
+	if err != nil || l == nil {
+		return "", false, err
+	}
+	return db.popList(key, l, front), true, nil
+}
+
+// popList removes an element from l, stored at key, without locking.
+// l must not be empty.
+func (db *database) popList(key string, l *List, front bool) string {
+	oldSize := l.Size()
+	var s string
+	if front {
+		s, _ = l.PopFront()
+	} else {
+		s, _ = l.PopBack()
+	}
+	db.updateContainer(key, l, oldSize)
+	return s
+}
+
+// LLen returns the length of the list stored at key
+func (db *database) LLen(key string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	l, err := db.getList(key)
+	if err != nil || l == nil {
+		return 0, err
+	}
+	return l.Len(), nil
+}
+
+// LIndex returns the element at index in the list stored at key.
+// Negative indexes count from the tail of the list. It returns false if index is out of range.
+func (db *database) LIndex(key string, index int) (string, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	l, err := db.getList(key)
+	if err != nil || l == nil {
+		return "", false, err
+	}
+	s, err := l.Get(index)
+	if err != nil {
+		return "", false, nil
+	}
+	return s, true, nil
+}
+
+// LSet replaces the element at index in the list stored at key.
+// It returns ErrNotFound if the key doesn't exist and ErrIndexOutOfRange if index is out of range.
+func (db *database) LSet(key string, index int, value string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	l, err := db.getList(key)
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		return ErrNotFound
+	}
+
+	if err := db.checkSize(l.Size() + uint64(len(value))); err != nil {
+		return err
+	}
+	oldSize := l.Size()
+	if err := l.Set(index, value); err != nil {
+		return err
+	}
+	return db.update(key, l, oldSize)
+}
+
+// LRange returns the elements of the list stored at key between start and stop, both inclusive.
+// Negative indexes count from the tail of the list.
+func (db *database) LRange(key string, start, stop int) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	l, err := db.getList(key)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		return []string{}, nil
+	}
+	return l.Range(start, stop), nil
+}
+
+// LTrim trims the list stored at key so that it only contains the elements between
+// start and stop, both inclusive
+func (db *database) LTrim(key string, start, stop int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	l, err := db.getList(key)
+	if err != nil || l == nil {
+		return err
+	}
+
+	oldSize := l.Size()
+	l.Trim(start, stop)
+	db.updateContainer(key, l, oldSize)
+	return nil
+}
+
+// LInsertBefore inserts value before the first occurrence of pivot in the list stored at key.
+// It returns the length of the list, -1 if pivot wasn't found, or 0 if the key doesn't exist.
+func (db *database) LInsertBefore(key string, pivot, value string) (int, error) {
+	return db.insert(key, pivot, value, 0)
+}
+
+// LInsertAfter inserts value after the first occurrence of pivot in the list stored at key.
+// It returns the length of the list, -1 if pivot wasn't found, or 0 if the key doesn't exist.
+func (db *database) LInsertAfter(key string, pivot, value string) (int, error) {
+	return db.insert(key, pivot, value, 1)
+}
+
+func (db *database) insert(key string, pivot, value string, offset int) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	l, err := db.getList(key)
+	if err != nil || l == nil {
+		return 0, err
+	}
+
+	i := l.Index(pivot)
+	if i < 0 {
+		return -1, nil
+	}
+
+	if err := db.checkSize(l.Size() + uint64(len(value))); err != nil {
+		return 0, err
+	}
+	oldSize := l.Size()
+	_ = l.Insert(i+offset, value)
+	if err := db.update(key, l, oldSize); err != nil {
+		return 0, err
+	}
+	return l.Len(), nil
+}
+
+// LRem removes the first count occurrences of value from the list stored at key,
+// see List.RemoveValue. It returns the number of removed elements.
+func (db *database) LRem(key string, count int, value string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	l, err := db.getList(key)
+	if err != nil || l == nil {
+		return 0, err
+	}
+
+	oldSize := l.Size()
+	n := l.RemoveValue(count, value)
+	db.updateContainer(key, l, oldSize)
+	return n, nil
+}
+
+// Direction selects an end of a list
+type Direction int
+
+const (
+	// Left is the head of a list
+	Left Direction = iota
+	// Right is the tail of a list
+	Right
+)
+
+// LMove atomically pops an element from the from end of the list stored at src and pushes it
+// to the to end of the list stored at dst, creating it if needed. src and dst may be the same key.
+// It returns false if src doesn't exist.
+func (db *database) LMove(src, dst string, from, to Direction) (string, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	l, err := db.getList(src)
+	if err != nil || l == nil {
+		return "", false, err
+	}
+	s, err := db.moveList(src, l, dst, from, to)
+	if err != nil {
+		return "", false, err
+	}
+	return s, true, nil
+}
+
+// moveList moves an element of l, stored at src, to dst without locking.
+// Nothing is popped if dst holds another type or can't grow within the cache capacity.
+func (db *database) moveList(src string, l *List, dst string, from, to Direction) (string, error) {
+	d, err := db.getList(dst)
+	if err != nil {
+		return "", err
+	}
+	i := 0
+	if from == Right {
+		i = -1
+	}
+	s, _ := l.Get(i)
+	if d != nil && d != l {
+		if err := db.checkSize(d.Size() + uint64(len(s))); err != nil {
+			return "", err
+		}
+	}
+	s = db.popList(src, l, from == Left)
+	if _, err := db.pushList(dst, to == Left, []string{s}); err != nil {
+		return "", err
+	}
+	db.signal(dst)
+	return s, nil
+}
+
+// BLPop pops the first element of the first non-empty list among keys, and returns its key.
+// If all the lists are empty, it blocks until an element is pushed to one of them or ctx is done,
+// in which case it returns ctx.Err(). Use context.WithTimeout for a timeout.
+// Goroutines blocked on the same key are served in the order they started waiting.
+func (db *database) BLPop(ctx context.Context, keys ...string) (string, string, error) {
+	return db.wait(ctx, &waiter{keys: keys, from: Left})
+}
+
+// BRPop is like BLPop, but pops the last element of the list
+func (db *database) BRPop(ctx context.Context, keys ...string) (string, string, error) {
+	return db.wait(ctx, &waiter{keys: keys, from: Right})
+}
+
+// BLMove is like LMove, but blocks until src has an element or ctx is done, see BLPop
+func (db *database) BLMove(ctx context.Context, src, dst string, from, to Direction) (string, error) {
+	_, s, err := db.wait(ctx, &waiter{keys: []string{src}, from: from, move: true, dst: dst, to: to})
+	return s, err
+}
+
+// waiter is a goroutine blocked on one or more list keys
+type waiter struct {
+	keys []string
+	// elements holds the position of the waiter in the queue of each key
+	elements []*list.Element
+	from     Direction
+	// move is set for BLMove, which pushes the element to dst
+	move bool
+	dst  string
+	to   Direction
+	done chan popResult
+}
+
+// popResult is what a blocked waiter receives when it is served
+type popResult struct {
+	key   string
+	value string
+	err   error
+}
+
+// wait serves w right away if one of its lists has an element,
+// otherwise it queues w and blocks until it is served or ctx is done.
+func (db *database) wait(ctx context.Context, w *waiter) (string, string, error) {
+	db.mu.Lock()
+	for _, key := range w.keys {
+		l, err := db.getList(key)
+		if err != nil {
+			db.mu.Unlock()
+			return "", "", err
+		}
+		if l != nil {
+			r := db.serve(key, l, w)
+			db.mu.Unlock()
+			return r.key, r.value, r.err
+		}
+	}
+	w.done = make(chan popResult, 1)
+	db.block(w)
+	db.mu.Unlock()
+
+	select {
+	case r := <-w.done:
+		return r.key, r.value, r.err
+	case <-ctx.Done():
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	select {
+	case r := <-w.done:
+		// served while we were waiting for the lock, don't lose the element
+		return r.key, r.value, r.err
+	default:
+	}
+	db.unblock(w)
+	return "", "", ctx.Err()
+}
+
+// block appends w to the queue of each of its keys
+func (db *database) block(w *waiter) {
+	w.elements = make([]*list.Element, len(w.keys))
+	for i, key := range w.keys {
+		q := db.waiters[key]
+		if q == nil {
+			q = list.New()
+			db.waiters[key] = q
+		}
+		w.elements[i] = q.PushBack(w)
+	}
+}
+
+// unblock removes w from the queue of each of its keys
+func (db *database) unblock(w *waiter) {
+	for i, key := range w.keys {
+		q := db.waiters[key]
+		q.Remove(w.elements[i])
+		if q.Len() == 0 {
+			delete(db.waiters, key)
+		}
+	}
+}
+
+// signal serves the goroutines blocked on key, in FIFO order, while the list stored at key
+// has elements. It must be called without locking after a list is created at key.
+func (db *database) signal(key string) {
+	for q := db.waiters[key]; q != nil; q = db.waiters[key] {
+		l, err := db.getList(key)
+		if err != nil || l == nil {
+			return
+		}
+		w := q.Front().Value.(*waiter)
+		db.unblock(w)
+		w.done <- db.serve(key, l, w)
+	}
+}
+
+// serve pops an element for w from l, stored at key
+func (db *database) serve(key string, l *List, w *waiter) popResult {
+	if !w.move {
+		return popResult{key: key, value: db.popList(key, l, w.from == Left)}
+	}
+	s, err := db.moveList(key, l, w.dst, w.from, w.to)
+	return popResult{key: key, value: s, err: err}
+}
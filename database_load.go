@@ -0,0 +1,90 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Loader reads the value of key from the backend of a database.
+// It returns ErrNotFound if key doesn't exist there.
+type Loader func(ctx context.Context, key string) (Valuer, error)
+
+// loadCall is a call of a Loader shared by concurrent Loads of the same key
+type loadCall struct {
+	done  chan struct{}
+	value Valuer
+	err   error
+}
+
+// SetGuard sets the guard of the database, or removes it if g is nil.
+// Keys stored afterwards are added to g, which should already know the keys stored before.
+func (db *database) SetGuard(g Guard) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.guard = g
+}
+
+// Load returns the value stored under key, or reads it with loader and stores it on a miss.
+// If the database has a guard reporting key as absent, it returns ErrNotFound without calling loader.
+// Concurrent Loads of the same key share a single call of loader.
+func (db *database) Load(ctx context.Context, key string, loader Loader) (Valuer, error) {
+	db.mu.Lock()
+	v, err := db.lookup(key)
+	guard := db.guard
+	db.mu.Unlock()
+	if err == nil {
+		return v, nil
+	}
+	if guard != nil && !guard.MayExist(key) {
+		return nil, ErrNotFound
+	}
+
+	db.loadMu.Lock()
+	call, ok := db.loads[key]
+	if !ok {
+		call = &loadCall{done: make(chan struct{})}
+		if db.loads == nil {
+			db.loads = make(map[string]*loadCall)
+		}
+		db.loads[key] = call
+		go db.load(context.WithoutCancel(ctx), key, loader, call)
+	}
+	db.loadMu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load runs loader for key, stores the value it returns and completes call.
+// ctx isn't canceled with the first Load, which may give up before the others.
+// A value too large for the cache is returned without being stored.
+// A panic of loader is returned to all the Loads as an error wrapping ErrLoaderPanic.
+func (db *database) load(ctx context.Context, key string, loader Loader, call *loadCall) {
+	call.value, call.err = callLoader(ctx, key, loader)
+	if call.err == nil {
+		if err := db.SetValue(key, call.value); err != nil && !errors.Is(err, ErrOutOfMemory) {
+			call.err = err
+		}
+	}
+
+	db.loadMu.Lock()
+	delete(db.loads, key)
+	db.loadMu.Unlock()
+	close(call.done)
+}
+
+// callLoader calls loader, turning a panic into an error wrapping ErrLoaderPanic
+func callLoader(ctx context.Context, key string, loader Loader) (v Valuer, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = nil, fmt.Errorf("%w: %v", ErrLoaderPanic, r)
+		}
+	}()
+	return loader(ctx, key)
+}
@@ -0,0 +1,173 @@
+package mycache
+
+import "time"
+
+// QPush appends messages with bodies to the queue stored at key and returns their ids.
+// The queue is created with DefaultQueueOptions if needed; store a NewQueue with SetValue
+// beforehand to use other options.
+func (db *database) QPush(key string, bodies ...string) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	q, err := db.getQueue(key)
+	if err != nil {
+		return nil, err
+	}
+	created := q == nil
+	if created {
+		q = NewQueue(DefaultQueueOptions)
+	}
+	// each message also stores its id twice, in the messages and in the ready list
+	size := q.Size()
+	for _, body := range bodies {
+		size += uint64(len(body) + 2*maxInt64Len)
+	}
+	if err := db.checkSize(size); err != nil {
+		return nil, err
+	}
+	if created {
+		if err := db.set(key, q); err != nil {
+			return nil, err
+		}
+	}
+
+	oldSize := q.Size()
+	ids := make([]string, len(bodies))
+	for i, body := range bodies {
+		ids[i] = q.Push(body)
+	}
+	if err := db.update(key, q, oldSize); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// QReserve delivers the first ready message of the queue stored at key. The message is invisible
+// to other consumers until it's acknowledged with QAck, or until the visibility timeout of the queue
+// elapses and it's delivered again. It returns false if no message is ready.
+func (db *database) QReserve(key string) (Message, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	q, err := db.getQueue(key)
+	if err != nil || q == nil {
+		return Message{}, false, err
+	}
+
+	now := time.Now()
+	if err := db.requeue(key, q, now); err != nil {
+		return Message{}, false, err
+	}
+	oldSize := q.Size()
+	m, ok := q.Reserve(now)
+	if err := db.update(key, q, oldSize); err != nil {
+		return Message{}, false, err
+	}
+	return m, ok, nil
+}
+
+// QAck acknowledges the reserved message with id of the queue stored at key, deleting it.
+// It returns false if the message isn't reserved anymore, e.g. because it timed out.
+func (db *database) QAck(key string, id string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	q, err := db.getQueue(key)
+	if err != nil || q == nil {
+		return false, err
+	}
+
+	oldSize := q.Size()
+	ok := q.Ack(id)
+	return ok, db.update(key, q, oldSize)
+}
+
+// QNack returns the reserved message with id to the head of the queue stored at key, or moves it to
+// the dead letter list if it reached the delivery limit. It returns false if the message isn't reserved.
+func (db *database) QNack(key string, id string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	q, err := db.getQueue(key)
+	if err != nil || q == nil {
+		return false, err
+	}
+
+	if err := db.checkDeadLetter(q, q.Dying(id)); err != nil {
+		return false, err
+	}
+	oldSize := q.Size()
+	dead, ok := q.Nack(id)
+	if err := db.update(key, q, oldSize); err != nil || dead == nil {
+		return ok, err
+	}
+	return ok, db.deadLetter(q, []Message{*dead})
+}
+
+// QLen returns the number of ready and reserved messages of the queue stored at key
+func (db *database) QLen(key string) (ready int, inflight int, err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	q, err := db.getQueue(key)
+	if err != nil || q == nil {
+		return 0, 0, err
+	}
+	if err := db.requeue(key, q, time.Now()); err != nil {
+		return 0, 0, err
+	}
+	return q.Ready(), q.Inflight(), nil
+}
+
+// requeue returns the timed out messages of q, stored at key, to the queue without locking,
+// and moves the dead ones to the dead letter list. They all stay in flight if the dead letter
+// list can't receive the dead ones.
+func (db *database) requeue(key string, q *Queue, now time.Time) error {
+	if err := db.checkDeadLetter(q, q.Dying(q.TimedOut(now)...)); err != nil {
+		return err
+	}
+	oldSize := q.Size()
+	dead := q.Requeue(now)
+	if err := db.update(key, q, oldSize); err != nil || len(dead) == 0 {
+		return err
+	}
+	return db.deadLetter(q, dead)
+}
+
+// checkDeadLetter returns an error without locking if the dead letter list of q can't receive
+// the bodies of dead, because it holds another type or would outgrow the cache capacity
+func (db *database) checkDeadLetter(q *Queue, dead []Message) error {
+	key := q.Options().DeadLetterKey
+	if key == "" || len(dead) == 0 {
+		return nil
+	}
+	l, err := db.getList(key)
+	if err != nil {
+		return err
+	}
+	var size uint64
+	if l != nil {
+		size = l.Size()
+	}
+	for _, m := range dead {
+		size += uint64(len(m.Body))
+	}
+	return db.checkSize(size)
+}
+
+// deadLetter appends the bodies of dead messages of q to its dead letter list without locking
+func (db *database) deadLetter(q *Queue, dead []Message) error {
+	key := q.Options().DeadLetterKey
+	if key == "" {
+		return nil
+	}
+	bodies := make([]string, len(dead))
+	for i, m := range dead {
+		bodies[i] = m.Body
+	}
+	if _, err := db.pushList(key, false, bodies); err != nil {
+		return err
+	}
+	db.signal(key)
+	return nil
+}
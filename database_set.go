@@ -0,0 +1,289 @@
+package mycache
+
+import "math"
+
+// SAdd adds members to the set stored at key, creating the set if needed,
+// and returns the number of members that were added
+func (db *database) SAdd(key string, members ...string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	set, err := db.getSet(key)
+	if err != nil {
+		return 0, err
+	}
+	if set == nil {
+		if len(members) == 0 {
+			return 0, nil
+		}
+		set = NewEmptySet()
+	}
+	var bytes uint64
+	for _, m := range members {
+		bytes += uint64(len(m))
+	}
+	if err := db.checkSize(set.maxSizeWith(len(members), bytes)); err != nil {
+		return 0, err
+	}
+	if set.Len() == 0 {
+		db.set(key, set)
+	}
+
+	oldSize := set.Size()
+	n := set.Len()
+	for _, m := range members {
+		set.Add(m)
+	}
+	if err := db.update(key, set, oldSize); err != nil {
+		return 0, err
+	}
+	return set.Len() - n, nil
+}
+
+// SRem removes members from the set stored at key and returns the number of removed members
+func (db *database) SRem(key string, members ...string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	set, err := db.getSet(key)
+	if err != nil || set == nil {
+		return 0, err
+	}
+
+	oldSize := set.Size()
+	n := set.Len()
+	for _, m := range members {
+		set.Remove(m)
+	}
+	removed := n - set.Len()
+	db.updateContainer(key, set, oldSize)
+	return removed, nil
+}
+
+// SIsMember returns whether member is in the set stored at key
+func (db *database) SIsMember(key string, member string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	set, err := db.getSet(key)
+	if err != nil || set == nil {
+		return false, err
+	}
+	return set.Contains(member), nil
+}
+
+// SCard returns the number of members in the set stored at key
+func (db *database) SCard(key string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	set, err := db.getSet(key)
+	if err != nil || set == nil {
+		return 0, err
+	}
+	return set.Len(), nil
+}
+
+// SMembers returns all the members of the set stored at key
+func (db *database) SMembers(key string) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	set, err := db.getSet(key)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return []string{}, nil
+	}
+	return set.GetAll(), nil
+}
+
+// SPop removes and returns a random member of the set stored at key
+func (db *database) SPop(key string) (string, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	set, err := db.getSet(key)
+	if err != nil || set == nil {
+		return "", false, err
+	}
+
+	oldSize := set.Size()
+	m, ok := set.Pop()
+	db.updateContainer(key, set, oldSize)
+	return m, ok, nil
+}
+
+// SRandMember returns random members of the set stored at key without removing them.
+// See Set.Random for the meaning of count.
+func (db *database) SRandMember(key string, count int) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.checkSampleCount(count); err != nil {
+		return nil, err
+	}
+	set, err := db.getSet(key)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return []string{}, nil
+	}
+	return set.Random(count), nil
+}
+
+// checkSampleCount returns ErrOutOfMemory if a random sample with repetitions of -count
+// elements can't fit in the cache capacity
+func (db *database) checkSampleCount(count int) error {
+	if count == math.MinInt || (count < 0 && uint64(-count) > db.mycache.capacity/stringHeaderSize) {
+		return ErrOutOfMemory
+	}
+	return nil
+}
+
+// SMove moves member from the set stored at src to the set stored at dst,
+// and returns whether member was moved
+func (db *database) SMove(src, dst string, member string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	srcSet, err := db.getSet(src)
+	if err != nil {
+		return false, err
+	}
+	dstSet, err := db.getSet(dst)
+	if err != nil {
+		return false, err
+	}
+	if srcSet == nil || !srcSet.Contains(member) {
+		return false, nil
+	}
+	if src == dst {
+		return true, nil
+	}
+
+	if dstSet == nil {
+		dstSet = NewEmptySet()
+	}
+	if err := db.checkSize(dstSet.maxSizeWith(1, uint64(len(member)))); err != nil {
+		return false, err
+	}
+
+	// remove from src first: growing dst may evict src, which must be accounted for by then
+	oldSize := srcSet.Size()
+	srcSet.Remove(member)
+	db.updateContainer(src, srcSet, oldSize)
+
+	if dstSet.Len() == 0 {
+		if err := db.set(dst, dstSet); err != nil {
+			db.restoreMember(src, srcSet, member)
+			return false, err
+		}
+	}
+	oldSize = dstSet.Size()
+	dstSet.Add(member)
+	return true, db.update(dst, dstSet, oldSize)
+}
+
+// restoreMember adds member back to srcSet, stored at src, after a failed move.
+// srcSet is stored again if removing member deleted it.
+func (db *database) restoreMember(src string, srcSet *Set, member string) {
+	oldSize := srcSet.Size()
+	srcSet.Add(member)
+	if _, ok := db.get(src); !ok {
+		db.set(src, srcSet)
+		return
+	}
+	db.update(src, srcSet, oldSize)
+}
+
+// SInter returns the members of the intersection of the sets stored at keys
+func (db *database) SInter(keys ...string) ([]string, error) {
+	return db.setOp(keys, (*Set).Intersect)
+}
+
+// SUnion returns the members of the union of the sets stored at keys
+func (db *database) SUnion(keys ...string) ([]string, error) {
+	return db.setOp(keys, (*Set).Union)
+}
+
+// SDiff returns the members of the set stored at the first key that are not
+// in any of the sets stored at the following keys
+func (db *database) SDiff(keys ...string) ([]string, error) {
+	return db.setOp(keys, (*Set).Diff)
+}
+
+// SInterStore stores the intersection of the sets stored at keys in dst,
+// and returns the number of members in the result
+func (db *database) SInterStore(dst string, keys ...string) (int, error) {
+	return db.setOpStore(dst, keys, (*Set).Intersect)
+}
+
+// SUnionStore stores the union of the sets stored at keys in dst,
+// and returns the number of members in the result
+func (db *database) SUnionStore(dst string, keys ...string) (int, error) {
+	return db.setOpStore(dst, keys, (*Set).Union)
+}
+
+// SDiffStore stores the difference between the set stored at the first key and the sets
+// stored at the following keys in dst, and returns the number of members in the result
+func (db *database) SDiffStore(dst string, keys ...string) (int, error) {
+	return db.setOpStore(dst, keys, (*Set).Diff)
+}
+
+func (db *database) setOp(keys []string, op func(*Set, ...*Set) *Set) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	res, err := db.combineSets(keys, op)
+	if err != nil {
+		return nil, err
+	}
+	return res.GetAll(), nil
+}
+
+func (db *database) setOpStore(dst string, keys []string, op func(*Set, ...*Set) *Set) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	res, err := db.combineSets(keys, op)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.overwrite(dst, res); err != nil {
+		return 0, err
+	}
+	return res.Len(), nil
+}
+
+// combineSets applies op to the sets stored at keys without locking.
+// Keys that don't exist are empty sets.
+func (db *database) combineSets(keys []string, op func(*Set, ...*Set) *Set) (*Set, error) {
+	sets, err := db.getSets(keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(sets) == 0 {
+		return NewEmptySet(), nil
+	}
+	first := sets[0]
+	if first == nil {
+		first = NewEmptySet()
+	}
+	return op(first, sets[1:]...), nil
+}
+
+// getSets returns the sets stored at keys, with nil for keys that don't exist.
+func (db *database) getSets(keys []string) ([]*Set, error) {
+	sets := make([]*Set, len(keys))
+	for i, key := range keys {
+		set, err := db.getSet(key)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
@@ -0,0 +1,185 @@
+package mycache
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// setString stores str under key, reusing s if the key already holds a String.
+// s is left unchanged if str doesn't fit in the cache capacity.
+func (db *database) setString(key string, s *String, str string) error {
+	if s == nil {
+		return db.set(key, NewString(str))
+	}
+	if err := db.checkSize(uint64(len(str))); err != nil {
+		return err
+	}
+	oldSize := s.Size()
+	s.s = str
+	return db.update(key, s, oldSize)
+}
+
+// Incr increments the integer stored at key by one
+func (db *database) Incr(key string) (int64, error) {
+	return db.IncrBy(key, 1)
+}
+
+// Decr decrements the integer stored at key by one
+func (db *database) Decr(key string) (int64, error) {
+	return db.IncrBy(key, -1)
+}
+
+// DecrBy decrements the integer stored at key by delta
+func (db *database) DecrBy(key string, delta int64) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+	return db.IncrBy(key, -delta)
+}
+
+// IncrBy increments the integer stored at key by delta. A missing key is treated as 0.
+func (db *database) IncrBy(key string, delta int64) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	s, err := db.getString(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	if s != nil {
+		n, err = strconv.ParseInt(s.s, 10, 64)
+		if err != nil {
+			return 0, ErrNotInteger
+		}
+	}
+	if n, err = addInt64(n, delta); err != nil {
+		return 0, err
+	}
+
+	if err := db.setString(key, s, strconv.FormatInt(n, 10)); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// addInt64 returns n+delta, or ErrOverflow if the result doesn't fit in an int64.
+func addInt64(n, delta int64) (int64, error) {
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+	return n + delta, nil
+}
+
+// Append appends value to the string stored at key and returns the new length
+func (db *database) Append(key string, value string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	s, err := db.getString(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var str string
+	if s != nil {
+		str = s.s
+	}
+	str += value
+	if err := db.setString(key, s, str); err != nil {
+		return 0, err
+	}
+	return len(str), nil
+}
+
+// GetRange returns the substring of the string stored at key between the byte offsets
+// start and end, both inclusive. Negative offsets count from the end of the string.
+func (db *database) GetRange(key string, start, end int) (string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	s, err := db.getString(key)
+	if err != nil || s == nil {
+		return "", err
+	}
+
+	lo, hi, ok := normalizeRange(start, end, len(s.s))
+	if !ok {
+		return "", nil
+	}
+	return s.s[lo:hi], nil
+}
+
+// SetRange overwrites the string stored at key starting at offset, padding it with zero bytes
+// if needed, and returns the new length.
+// It returns ErrOutOfMemory, before allocating, if the string would outgrow the cache capacity.
+func (db *database) SetRange(key string, offset int, value string) (int, error) {
+	if offset < 0 || offset > math.MaxInt-len(value) {
+		return 0, ErrOffsetOutOfRange
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	s, err := db.getString(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var b []byte
+	if s != nil {
+		b = []byte(s.s)
+	}
+	if len(value) == 0 {
+		return len(b), nil
+	}
+	if err := db.checkSize(uint64(offset + len(value))); err != nil {
+		return 0, err
+	}
+	if end := offset + len(value); end > len(b) {
+		b = append(b, make([]byte, end-len(b))...)
+	}
+	copy(b[offset:], value)
+
+	if err := db.setString(key, s, string(b)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// GetAndSet sets key to value and returns the old value, and whether it existed.
+// The expire time of the key is discarded.
+func (db *database) GetAndSet(key string, value string) (string, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	s, err := db.getString(key)
+	if err != nil {
+		return "", false, err
+	}
+
+	var old string
+	if s != nil {
+		old = s.s
+	}
+	if err := db.setWithExpireTime(key, NewString(value), time.Time{}); err != nil {
+		return "", false, err
+	}
+	return old, s != nil, nil
+}
+
+// SetNX sets key to value only if the key doesn't exist, and returns whether it was set
+func (db *database) SetNX(key string, value string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.get(key); ok {
+		return false, nil
+	}
+	if err := db.set(key, NewString(value)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,173 @@
+package mycache
+
+// getTDigest returns the TDigest stored under key without locking, or nil if the key doesn't exist
+func (db *database) getTDigest(key string) (*TDigest, error) {
+	v, ok := db.get(key)
+	if !ok {
+		return nil, nil
+	}
+	t, ok := v.(*TDigest)
+	if !ok {
+		return nil, wrongType(key, "TDigest", v)
+	}
+	return t, nil
+}
+
+// TDigestCreate creates an empty TDigest under key, see NewTDigest.
+// It returns ErrKeyExists if key already exists.
+func (db *database) TDigestCreate(key string, compression float64) error {
+	t, err := NewTDigest(compression)
+	if err != nil {
+		return err
+	}
+	return db.reserve(key, t.Size(), func() Valuer { return t })
+}
+
+// TDigestAdd adds samples to the TDigest stored at key
+func (db *database) TDigestAdd(key string, values ...float64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.getTDigest(key)
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return ErrNotFound
+	}
+	oldSize := t.Size()
+	if err := t.Add(values...); err != nil {
+		return err
+	}
+	return db.update(key, t, oldSize)
+}
+
+// TDigestQuantile returns estimates of the values below which the fractions qs of the samples
+// of the TDigest stored at key fall, or NaNs if it's empty
+func (db *database) TDigestQuantile(key string, qs ...float64) ([]float64, error) {
+	var values []float64
+	err := db.readTDigest(key, func(t *TDigest) error {
+		values = make([]float64, len(qs))
+		for i, q := range qs {
+			var err error
+			if values[i], err = t.Quantile(q); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return values, err
+}
+
+// TDigestCDF returns estimates of the fractions of the samples of the TDigest stored at key
+// that are at most each of xs, or NaNs if it's empty
+func (db *database) TDigestCDF(key string, xs ...float64) ([]float64, error) {
+	var fractions []float64
+	err := db.readTDigest(key, func(t *TDigest) error {
+		fractions = make([]float64, len(xs))
+		for i, x := range xs {
+			fractions[i] = t.CDF(x)
+		}
+		return nil
+	})
+	return fractions, err
+}
+
+// readTDigest calls fn with the TDigest stored at key, accounting for the samples it merges
+func (db *database) readTDigest(key string, fn func(t *TDigest) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.getTDigest(key)
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return ErrNotFound
+	}
+	oldSize := t.Size()
+	fnErr := fn(t)
+	if err := db.update(key, t, oldSize); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// TDigestMin returns the smallest sample of the TDigest stored at key, or NaN if it's empty
+func (db *database) TDigestMin(key string) (float64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.getTDigest(key)
+	if err != nil {
+		return 0, err
+	}
+	if t == nil {
+		return 0, ErrNotFound
+	}
+	return t.Min(), nil
+}
+
+// TDigestMax returns the largest sample of the TDigest stored at key, or NaN if it's empty
+func (db *database) TDigestMax(key string) (float64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.getTDigest(key)
+	if err != nil {
+		return 0, err
+	}
+	if t == nil {
+		return 0, ErrNotFound
+	}
+	return t.Max(), nil
+}
+
+// TDigestReset removes all the samples of the TDigest stored at key
+func (db *database) TDigestReset(key string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.getTDigest(key)
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return ErrNotFound
+	}
+	oldSize := t.Size()
+	t.Reset()
+	return db.update(key, t, oldSize)
+}
+
+// TDigestMerge adds to the TDigest stored at dst the samples of those stored at keys.
+// If dst doesn't exist, it's created with the largest compression among them.
+func (db *database) TDigestMerge(dst string, keys ...string) error {
+	return db.TDigestMergeFrom(db, dst, keys...)
+}
+
+// TDigestMergeFrom is like TDigestMerge, with the TDigests stored at keys in the database src
+func (db *database) TDigestMergeFrom(src *database, dst string, keys ...string) error {
+	return mergeFrom(db, src, keys, (*database).getTDigest, (*TDigest).Clone, func(srcs []*TDigest) error {
+		t, err := db.getTDigest(dst)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			compression := float64(DefaultTDigestCompression)
+			if len(srcs) > 0 {
+				compression = 0
+				for _, src := range srcs {
+					compression = max(compression, src.compression)
+				}
+			}
+			t, _ = NewTDigest(compression)
+			if err := db.set(dst, t); err != nil {
+				return err
+			}
+		}
+		oldSize := t.Size()
+		t.Merge(srcs...)
+		return db.update(dst, t, oldSize)
+	})
+}
@@ -0,0 +1,133 @@
+package mycache
+
+// getTopK returns the TopK stored under key without locking, or nil if the key doesn't exist
+func (db *database) getTopK(key string) (*TopK, error) {
+	v, ok := db.get(key)
+	if !ok {
+		return nil, nil
+	}
+	t, ok := v.(*TopK)
+	if !ok {
+		return nil, wrongType(key, "TopK", v)
+	}
+	return t, nil
+}
+
+// TopKReserve creates an empty TopK under key keeping k items, see NewTopK.
+// It returns ErrKeyExists if key already exists.
+func (db *database) TopKReserve(key string, k, width, depth int, decay float64) error {
+	width, depth, decay, err := topKParameters(k, width, depth, decay)
+	if err != nil {
+		return err
+	}
+	return db.reserve(key, uint64(8*width*depth), func() Valuer {
+		t, _ := NewTopK(k, width, depth, decay)
+		return t
+	})
+}
+
+// TopKAdd adds items to the TopK stored at key, and returns the items they expelled from the top
+func (db *database) TopKAdd(key string, items ...string) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrNotFound
+	}
+	// the top keeps at most one entry per item
+	size := t.Size()
+	for _, item := range items {
+		size += uint64(stringHeaderSize + 8 + len(item))
+	}
+	if err := db.checkSize(size); err != nil {
+		return nil, err
+	}
+	oldSize := t.Size()
+	var expelled []string
+	for _, item := range items {
+		if e, ok := t.Add(item); ok {
+			expelled = append(expelled, e)
+		}
+	}
+	return expelled, db.update(key, t, oldSize)
+}
+
+// TopKQuery returns whether each of items is in the top of the TopK stored at key
+func (db *database) TopKQuery(key string, items ...string) ([]bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrNotFound
+	}
+	res := make([]bool, len(items))
+	for i, item := range items {
+		res[i] = t.Query(item)
+	}
+	return res, nil
+}
+
+// TopKCount returns the estimated counts of items in the TopK stored at key
+func (db *database) TopKCount(key string, items ...string) ([]uint64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrNotFound
+	}
+	counts := make([]uint64, len(items))
+	for i, item := range items {
+		counts[i] = t.Count(item)
+	}
+	return counts, nil
+}
+
+// TopKList returns the items in the top of the TopK stored at key, the most frequent first
+func (db *database) TopKList(key string) ([]TopKItem, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrNotFound
+	}
+	return t.List(), nil
+}
+
+// TopKMerge merges into the TopK stored at dst those stored at keys, see TopK.Merge
+func (db *database) TopKMerge(dst string, keys ...string) error {
+	return db.TopKMergeFrom(db, dst, keys...)
+}
+
+// TopKMergeFrom is like TopKMerge, with the TopKs stored at keys in the database src
+func (db *database) TopKMergeFrom(src *database, dst string, keys ...string) error {
+	return mergeFrom(db, src, keys, (*database).getTopK, (*TopK).Clone, func(srcs []*TopK) error {
+		t, err := db.getTopK(dst)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			return ErrNotFound
+		}
+		oldSize := t.Size()
+		if err := t.Merge(srcs...); err != nil {
+			return err
+		}
+		return db.update(dst, t, oldSize)
+	})
+}
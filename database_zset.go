@@ -0,0 +1,510 @@
+package mycache
+
+import "math"
+
+// zsetOrCreate returns the Zset stored under key, storing an empty one if the key doesn't exist.
+func (db *database) zsetOrCreate(key string) (*Zset, error) {
+	zset, err := db.getZset(key)
+	if err != nil {
+		return nil, err
+	}
+	if zset == nil {
+		zset = NewZset()
+		db.set(key, zset)
+	}
+	return zset, nil
+}
+
+// ZAdd adds members to the sorted set stored at key, or updates their scores, according to opts.
+// It returns the number of added members, or the number of added and updated members if opts.CH is set.
+func (db *database) ZAdd(key string, opts ZAddOptions, members ...ZMember) (int, error) {
+	if err := opts.validate(); err != nil {
+		return 0, err
+	}
+	for _, m := range members {
+		if math.IsNaN(m.Score) {
+			return 0, ErrNaN
+		}
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.zsetOrCreate(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var bytes uint64
+	for _, m := range members {
+		bytes += uint64(len(m.Member))
+	}
+	if err := db.checkSize(zset.maxSizeWith(len(members), bytes)); err != nil {
+		db.updateContainer(key, zset, zset.Size())
+		return 0, err
+	}
+	oldSize := zset.Size()
+	n := 0
+	for _, m := range members {
+		_, res, _ := zset.add(opts, m.Score, m.Member, false)
+		if res == zaddAdded || (opts.CH && res == zaddUpdated) {
+			n++
+		}
+	}
+	err = db.update(key, zset, oldSize)
+	if zset.Len() == 0 {
+		db.remove(key)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// ZAddIncr increments the score of member in the sorted set stored at key by increment,
+// like ZADD with the INCR flag. It returns the new score, or false if opts prevented the update.
+func (db *database) ZAddIncr(key string, opts ZAddOptions, increment float64, member string) (float64, bool, error) {
+	if err := opts.validate(); err != nil {
+		return 0, false, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.zsetOrCreate(key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if err := db.checkSize(zset.maxSizeWith(1, uint64(len(member)))); err != nil {
+		db.updateContainer(key, zset, zset.Size())
+		return 0, false, err
+	}
+	oldSize := zset.Size()
+	score, res, err := zset.add(opts, increment, member, true)
+	if err == nil {
+		err = db.update(key, zset, oldSize)
+	}
+	if zset.Len() == 0 {
+		db.remove(key)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return score, res != zaddSkipped, nil
+}
+
+// ZIncrBy increments the score of member in the sorted set stored at key by increment,
+// adding member if needed, and returns the new score
+func (db *database) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	score, _, err := db.ZAddIncr(key, ZAddOptions{}, increment, member)
+	return score, err
+}
+
+// ZScore returns the score of member in the sorted set stored at key
+func (db *database) ZScore(key string, member string) (float64, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil || zset == nil {
+		return 0, false, err
+	}
+	score, ok := zset.Score(member)
+	return score, ok, nil
+}
+
+// ZRem removes members from the sorted set stored at key and returns the number of removed members
+func (db *database) ZRem(key string, members ...string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+
+	oldSize := zset.Size()
+	n := 0
+	for _, m := range members {
+		if zset.RemoveMember(m) {
+			n++
+		}
+	}
+	db.updateContainer(key, zset, oldSize)
+	return n, nil
+}
+
+// ZCard returns the number of members in the sorted set stored at key
+func (db *database) ZCard(key string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+	return zset.Len(), nil
+}
+
+// ZRange returns the members of the sorted set stored at key between the ranks start and stop,
+// both inclusive, ordered from the lowest score. Negative ranks count from the highest score.
+func (db *database) ZRange(key string, start, stop int) ([]ZMember, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil {
+		return nil, err
+	}
+	if zset == nil {
+		return []ZMember{}, nil
+	}
+	return zset.RangeByRank(start, stop), nil
+}
+
+// ZRevRange is like ZRange, but ordered from the highest score
+func (db *database) ZRevRange(key string, start, stop int) ([]ZMember, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil {
+		return nil, err
+	}
+	if zset == nil {
+		return []ZMember{}, nil
+	}
+	return zset.RevRangeByRank(start, stop), nil
+}
+
+// ZRangeByScore returns the members of the sorted set stored at key with a score between min and max,
+// ordered from the lowest score. It skips offset members and returns at most count members,
+// or all of them if count is negative.
+func (db *database) ZRangeByScore(key string, min, max ScoreBound, offset, count int) ([]ZMember, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil {
+		return nil, err
+	}
+	if zset == nil {
+		return []ZMember{}, nil
+	}
+	return zset.RangeByScore(min, max, offset, count), nil
+}
+
+// ZRevRangeByScore is like ZRangeByScore, but ordered from the highest score
+func (db *database) ZRevRangeByScore(key string, max, min ScoreBound, offset, count int) ([]ZMember, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil {
+		return nil, err
+	}
+	if zset == nil {
+		return []ZMember{}, nil
+	}
+	return zset.RevRangeByScore(max, min, offset, count), nil
+}
+
+// ZRank returns the rank of member in the sorted set stored at key, starting from 0 for the lowest score
+func (db *database) ZRank(key string, member string) (int, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil || zset == nil {
+		return 0, false, err
+	}
+	rank, ok := zset.Rank(member)
+	return rank, ok, nil
+}
+
+// ZRevRank returns the rank of member in the sorted set stored at key, starting from 0 for the highest score
+func (db *database) ZRevRank(key string, member string) (int, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil || zset == nil {
+		return 0, false, err
+	}
+	rank, ok := zset.RevRank(member)
+	return rank, ok, nil
+}
+
+// ZCount returns the number of members in the sorted set stored at key with a score between min and max
+func (db *database) ZCount(key string, min, max ScoreBound) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+	return zset.Count(min, max), nil
+}
+
+// ZRangeByLex returns the members of the sorted set stored at key between min and max,
+// when all members have the same score. See Zset.RangeByLex.
+func (db *database) ZRangeByLex(key string, min, max LexBound, offset, count int) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil {
+		return nil, err
+	}
+	if zset == nil {
+		return []string{}, nil
+	}
+	return zset.RangeByLex(min, max, offset, count), nil
+}
+
+// ZLexCount returns the number of members of the sorted set stored at key between min and max,
+// when all members have the same score
+func (db *database) ZLexCount(key string, min, max LexBound) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+	return zset.LexCount(min, max), nil
+}
+
+// ZRemRangeByLex removes the members of the sorted set stored at key between min and max,
+// when all members have the same score, and returns the number of removed members
+func (db *database) ZRemRangeByLex(key string, min, max LexBound) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+
+	oldSize := zset.Size()
+	n := zset.RemoveRangeByLex(min, max)
+	db.updateContainer(key, zset, oldSize)
+	return n, nil
+}
+
+// ZPopMin removes and returns up to count members with the lowest scores in the sorted set stored at key
+func (db *database) ZPopMin(key string, count int) ([]ZMember, error) {
+	return db.zpop(key, count, false)
+}
+
+// ZPopMax removes and returns up to count members with the highest scores in the sorted set stored at key
+func (db *database) ZPopMax(key string, count int) ([]ZMember, error) {
+	return db.zpop(key, count, true)
+}
+
+func (db *database) zpop(key string, count int, max bool) ([]ZMember, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil {
+		return nil, err
+	}
+	if zset == nil {
+		return []ZMember{}, nil
+	}
+
+	oldSize := zset.Size()
+	var members []ZMember
+	if max {
+		members = zset.PopMax(count)
+	} else {
+		members = zset.PopMin(count)
+	}
+	db.updateContainer(key, zset, oldSize)
+	return members, nil
+}
+
+// getScores returns the members and scores of the sorted sets or sets stored at keys,
+// with a score of 1 for the members of a set, and nil for keys that don't exist.
+func (db *database) getScores(keys []string) ([]map[string]float64, error) {
+	scores := make([]map[string]float64, len(keys))
+	for i, key := range keys {
+		v, ok := db.get(key)
+		if !ok {
+			continue
+		}
+		switch v := v.(type) {
+		case *Zset:
+			scores[i] = v.scores()
+		case *Set:
+			m := make(map[string]float64, v.Len())
+			for _, member := range v.GetAll() {
+				m[member] = 1
+			}
+			scores[i] = m
+		default:
+			return nil, wrongType(key, "Zset", v)
+		}
+	}
+	return scores, nil
+}
+
+// ZUnionStore stores in dst the union of the sorted sets or sets stored at keys,
+// combining scores according to opts, and returns the number of members in the result
+func (db *database) ZUnionStore(dst string, keys []string, opts ZStoreOptions) (int, error) {
+	return db.zstore(dst, keys, opts, false)
+}
+
+// ZInterStore stores in dst the intersection of the sorted sets or sets stored at keys,
+// combining scores according to opts, and returns the number of members in the result
+func (db *database) ZInterStore(dst string, keys []string, opts ZStoreOptions) (int, error) {
+	return db.zstore(dst, keys, opts, true)
+}
+
+func (db *database) zstore(dst string, keys []string, opts ZStoreOptions, inter bool) (int, error) {
+	if len(opts.Weights) > 0 && len(opts.Weights) != len(keys) {
+		return 0, ErrInvalidWeights
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	sources, err := db.getScores(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	res := NewZset()
+	if !inter {
+		scores := make(map[string]float64)
+		for i, src := range sources {
+			for member, score := range src {
+				score = weigh(score, opts.Weights, i)
+				if cur, ok := scores[member]; ok {
+					score = opts.Aggregate.apply(cur, score)
+				}
+				scores[member] = score
+			}
+		}
+		for member, score := range scores {
+			res.Add(score, member)
+		}
+	} else if len(sources) > 0 {
+	members:
+		for member, score := range sources[0] {
+			score = weigh(score, opts.Weights, 0)
+			for i, src := range sources[1:] {
+				s, ok := src[member]
+				if !ok {
+					continue members
+				}
+				score = opts.Aggregate.apply(score, weigh(s, opts.Weights, i+1))
+			}
+			res.Add(score, member)
+		}
+	}
+
+	if err := db.overwrite(dst, res); err != nil {
+		return 0, err
+	}
+	return res.Len(), nil
+}
+
+// weigh multiplies the score of the i-th source by its weight.
+func weigh(score float64, weights []float64, i int) float64 {
+	if len(weights) == 0 {
+		return score
+	}
+	// like Redis, 0 times infinity is 0
+	if w := score * weights[i]; !math.IsNaN(w) {
+		return w
+	}
+	return 0
+}
+
+// ZDiff returns the members of the sorted set stored at the first key that are not in any of
+// the sorted sets or sets stored at the following keys, ordered from the lowest score
+func (db *database) ZDiff(keys ...string) ([]ZMember, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	res, err := db.zdiff(keys)
+	if err != nil {
+		return nil, err
+	}
+	return res.RangeByRank(0, -1), nil
+}
+
+// ZDiffStore stores the result of ZDiff in dst and returns the number of members in the result
+func (db *database) ZDiffStore(dst string, keys ...string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	res, err := db.zdiff(keys)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.overwrite(dst, res); err != nil {
+		return 0, err
+	}
+	return res.Len(), nil
+}
+
+func (db *database) zdiff(keys []string) (*Zset, error) {
+	sources, err := db.getScores(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	res := NewZset()
+	if len(sources) == 0 {
+		return res, nil
+	}
+members:
+	for member, score := range sources[0] {
+		for _, src := range sources[1:] {
+			if _, ok := src[member]; ok {
+				continue members
+			}
+		}
+		res.Add(score, member)
+	}
+	return res, nil
+}
+
+// ZRemRangeByScore removes the members of the sorted set stored at key with a score between min and max,
+// and returns the number of removed members
+func (db *database) ZRemRangeByScore(key string, min, max ScoreBound) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+
+	oldSize := zset.Size()
+	n := zset.RemoveRangeByScore(min, max)
+	db.updateContainer(key, zset, oldSize)
+	return n, nil
+}
+
+// ZRemRangeByRank removes the members of the sorted set stored at key between the ranks start and stop,
+// both inclusive, and returns the number of removed members
+func (db *database) ZRemRangeByRank(key string, start, stop int) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	zset, err := db.getZset(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+
+	oldSize := zset.Size()
+	n := zset.RemoveRangeByRank(start, stop)
+	db.updateContainer(key, zset, oldSize)
+	return n, nil
+}
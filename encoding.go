@@ -0,0 +1,53 @@
+package mycache
+
+import "strconv"
+
+// Thresholds below which containers use a compact encoding, like the *-max-listpack-* settings of Redis.
+// They apply when a container grows: once converted to a larger encoding, a container keeps it.
+// They are shared by all the caches of the process and read without synchronization, so they must
+// be set before the first container is created, and not changed afterwards.
+var (
+	// SetMaxIntsetEntries is the maximum length of a Set of integers encoded as a sorted array
+	SetMaxIntsetEntries = 512
+	// SetMaxListpackEntries is the maximum length of a Set encoded as a small array
+	SetMaxListpackEntries = 128
+	// SetMaxListpackValue is the maximum length of a member of a Set encoded as a small array
+	SetMaxListpackValue = 64
+	// HashMaxListpackEntries is the maximum length of a Hash encoded as small arrays
+	HashMaxListpackEntries = 128
+	// HashMaxListpackValue is the maximum length of a field or value of a Hash encoded as small arrays
+	HashMaxListpackValue = 64
+	// ZsetMaxListpackEntries is the maximum length of a Zset encoded as a sorted array
+	ZsetMaxListpackEntries = 128
+	// ZsetMaxListpackValue is the maximum length of a member of a Zset encoded as a sorted array
+	ZsetMaxListpackValue = 64
+)
+
+// Encodings of containers, as returned by their Encoding method
+const (
+	EncodingIntset    = "intset"
+	EncodingListpack  = "listpack"
+	EncodingHashtable = "hashtable"
+	EncodingSkiplist  = "skiplist"
+)
+
+// Approximate memory footprints, used to compute the Size of containers
+const (
+	stringHeaderSize = 16
+	sliceHeaderSize  = 24
+	// mapEntryOverhead is the cost of a map entry besides its key and value, including unused slots
+	mapEntryOverhead = 24
+	// maxInt64Len is the length of the longest decimal form of an int64
+	maxInt64Len = 20
+	// maxFloatLen bounds the length of a float64 formatted by strconv.FormatFloat(f, 'f', -1, 64)
+	maxFloatLen = 350
+)
+
+// parseIntsetMember returns the integer represented by s, if s is its canonical decimal form
+func parseIntsetMember(s string) (int64, bool) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != s {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,61 @@
+package mycache
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotFound            = errors.New("key not found")
+	ErrWrongType           = errors.New("operation against a key holding the wrong kind of value")
+	ErrIndexOutOfRange     = errors.New("index out of range")
+	ErrOutOfMemory         = errors.New("value is larger than the cache capacity")
+	ErrExpired             = errors.New("key has expired")
+	ErrNotInteger          = errors.New("value is not an integer or out of range")
+	ErrOverflow            = errors.New("increment or decrement would overflow")
+	ErrOffsetOutOfRange    = errors.New("offset is out of range")
+	ErrNaN                 = errors.New("resulting score is not a number (NaN)")
+	ErrNotFloat            = errors.New("value is not a valid float")
+	ErrNaNOrInfinity       = errors.New("increment would produce NaN or Infinity")
+	ErrInvalidLexBound     = errors.New("min or max not valid string range item")
+	ErrInvalidWeights      = errors.New("number of weights doesn't match the number of keys")
+	ErrInvalidBit          = errors.New("bit is not an integer or out of range")
+	ErrInvalidBitFieldType = errors.New("invalid bitfield type, use i1 to i64 or u1 to u63")
+	ErrWrongNumberOfArgs   = errors.New("wrong number of arguments")
+	ErrBitOpNotArity       = errors.New("BITOP NOT must be called with a single source key")
+	ErrKeyExists           = errors.New("key already exists")
+	ErrLoaderPanic         = errors.New("loader panicked")
+	ErrSchedulerTarget     = errors.New("scheduler needs exactly one of Target and Handler")
+	ErrHandlerPanic        = errors.New("scheduler handler panicked")
+	ErrInvalidErrorRate    = errors.New("error rate must be between 0 and 1 exclusive")
+	ErrInvalidCapacity     = errors.New("capacity must be positive and not too large")
+	ErrInvalidDimensions   = errors.New("dimensions must be positive and not too large")
+	ErrInvalidDecay        = errors.New("decay must be between 0 and 1 exclusive")
+	ErrIncompatibleSketch  = errors.New("sketches have different dimensions")
+	ErrInvalidEncoding     = errors.New("invalid encoded value")
+	ErrInvalidCompression  = errors.New("compression must be positive")
+	ErrNotFinite           = errors.New("value is NaN or infinite")
+	ErrInvalidQuantile     = errors.New("quantile must be between 0 and 1")
+
+	ErrIncompatibleOptions = errors.New("options are not compatible")
+)
+
+// WrongTypeError is returned when an operation is applied to a key holding a value of another type.
+// It matches ErrWrongType with errors.Is.
+type WrongTypeError struct {
+	Key      string
+	Expected string
+	Actual   string
+}
+
+func (e *WrongTypeError) Error() string {
+	return fmt.Sprintf("WRONGTYPE key %q holds a %s, not a %s", e.Key, e.Actual, e.Expected)
+}
+
+func (e *WrongTypeError) Is(target error) bool {
+	return target == ErrWrongType
+}
+
+func wrongType(key, expected string, v Valuer) error {
+	return &WrongTypeError{Key: key, Expected: expected, Actual: v.Type()}
+}
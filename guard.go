@@ -0,0 +1,71 @@
+package mycache
+
+import (
+	"sync"
+
+	"github.com/RGBli/MyCache/util"
+)
+
+// Guard protects the backend of a database against lookups of keys that don't exist there,
+// known as cache penetration. Keys stored in the database are added to its guard, and Load
+// doesn't call its loader for keys the guard reports as absent.
+// A Guard must be safe for concurrent use.
+type Guard interface {
+	// Add records that key exists
+	Add(key string)
+	// MayExist returns false if key surely doesn't exist
+	MayExist(key string) bool
+}
+
+// BloomGuard is a Guard backed by a Bloom filter: it may let through a small fraction of absent keys
+type BloomGuard struct {
+	filter *util.SyncBloomFilter
+}
+
+// NewBloomGuard returns a BloomGuard sized for n keys, letting through a fraction fp of absent keys
+func NewBloomGuard(n int, fp float64) *BloomGuard {
+	return &BloomGuard{filter: util.NewSyncBloomFilter(util.NewWithEstimates(n, fp))}
+}
+
+func (g *BloomGuard) Add(key string) {
+	g.filter.Add([]byte(key))
+}
+
+func (g *BloomGuard) MayExist(key string) bool {
+	return g.filter.Contains([]byte(key))
+}
+
+// WhitelistGuard is a Guard holding the exact set of known keys
+type WhitelistGuard struct {
+	mu   sync.RWMutex
+	keys map[string]struct{}
+}
+
+// NewWhitelistGuard returns a WhitelistGuard that knows keys
+func NewWhitelistGuard(keys ...string) *WhitelistGuard {
+	g := &WhitelistGuard{keys: make(map[string]struct{}, len(keys))}
+	for _, key := range keys {
+		g.keys[key] = struct{}{}
+	}
+	return g
+}
+
+func (g *WhitelistGuard) Add(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.keys[key] = struct{}{}
+}
+
+// Remove forgets key, so that it's reported as absent until it's added again
+func (g *WhitelistGuard) Remove(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.keys, key)
+}
+
+func (g *WhitelistGuard) MayExist(key string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	_, ok := g.keys[key]
+	return ok
+}
@@ -1,22 +1,67 @@
 package mycache
 
+import (
+	"cmp"
+	"hash/fnv"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/RGBli/MyCache/util"
+)
+
+// Hash is a map of fields to values. Small hashes are stored compactly as arrays of fields
+// and values, see HashMaxListpackEntries.
+// Fields may have an expire time, and expired fields are removed by RemoveExpired.
 type Hash struct {
-	h map[string]string
+	enc string
+	// fields and values hold the entries of a listpack, in insertion order
+	fields []string
+	values []string
+	h      map[string]string
+	// bytes is the total length of the fields and values
+	bytes uint64
+	// expires holds the expire times of the fields that have one
+	expires map[string]time.Time
+	// nextExpire is at most the earliest time in expires
+	nextExpire time.Time
+	// scanIndex holds the fields in the order of Scan, built by Scan and dropped
+	// when fields are added or removed
+	scanIndex []scanEntry
 }
 
+type scanEntry struct {
+	sum   uint64
+	field string
+}
+
+// expireEntrySize is the size of an entry of Hash.expires, besides the field
+const expireEntrySize = stringHeaderSize + 24 + mapEntryOverhead
+
 func NewHash() *Hash {
-	return &Hash{h: make(map[string]string)}
+	return &Hash{enc: EncodingListpack}
 }
 
 func (h *Hash) Size() uint64 {
-	var size uint64 = 0
-	for k, v := range h.h {
-		size += uint64(len(k) + len(v))
+	size := uint64(expireEntrySize * len(h.expires))
+	if h.enc == EncodingListpack {
+		return size + uint64(2*sliceHeaderSize+2*stringHeaderSize*len(h.fields)) + h.bytes
 	}
-	return size
+	return size + uint64((2*stringHeaderSize+mapEntryOverhead)*len(h.h)) + h.bytes
+}
+
+// maxSizeWith returns an upper bound of the size of the hash once n fields and values of bytes
+// total length are set, whatever encoding it converts to
+func (h *Hash) maxSizeWith(n int, bytes uint64) uint64 {
+	size := uint64(expireEntrySize*len(h.expires) + 2*sliceHeaderSize + (2*stringHeaderSize+mapEntryOverhead)*(h.Len()+n))
+	return size + h.bytes + bytes
 }
 
 func (h *Hash) Len() int {
+	if h.enc == EncodingListpack {
+		return len(h.fields)
+	}
 	return len(h.h)
 }
 
@@ -24,15 +69,290 @@ func (h *Hash) Type() string {
 	return "Hash"
 }
 
+// Encoding returns how the hash is stored: EncodingListpack or EncodingHashtable
+func (h *Hash) Encoding() string {
+	return h.enc
+}
+
+// Put sets the value of field key, removing its expire time
 func (h *Hash) Put(key string, value string) {
+	h.Persist(key)
+	h.set(key, value)
+}
+
+// set sets the value of field key, keeping its expire time
+func (h *Hash) set(key string, value string) {
+	if h.enc == EncodingListpack {
+		i := slices.Index(h.fields, key)
+		switch {
+		case len(value) > HashMaxListpackValue || len(key) > HashMaxListpackValue:
+		case i >= 0:
+			h.bytes += uint64(len(value)) - uint64(len(h.values[i]))
+			h.values[i] = value
+			return
+		case len(h.fields) < HashMaxListpackEntries:
+			h.scanIndex = nil
+			h.fields = append(h.fields, key)
+			h.values = append(h.values, value)
+			h.bytes += uint64(len(key) + len(value))
+			return
+		}
+		h.convert()
+	}
+
+	if old, ok := h.h[key]; ok {
+		h.bytes -= uint64(len(old))
+	} else {
+		h.scanIndex = nil
+		h.bytes += uint64(len(key))
+	}
 	h.h[key] = value
+	h.bytes += uint64(len(value))
+}
+
+// convert moves the entries of a listpack to a map
+func (h *Hash) convert() {
+	h.h = make(map[string]string, len(h.fields)+1)
+	for i, field := range h.fields {
+		h.h[field] = h.values[i]
+	}
+	h.fields, h.values = nil, nil
+	h.enc = EncodingHashtable
 }
 
 func (h *Hash) Get(key string) (value string, ok bool) {
+	if h.enc == EncodingListpack {
+		if i := slices.Index(h.fields, key); i >= 0 {
+			return h.values[i], true
+		}
+		return "", false
+	}
 	value, ok = h.h[key]
 	return
 }
 
 func (h *Hash) Remove(key string) {
-	delete(h.h, key)
+	delete(h.expires, key)
+	if h.enc == EncodingListpack {
+		if i := slices.Index(h.fields, key); i >= 0 {
+			h.scanIndex = nil
+			h.bytes -= uint64(len(key) + len(h.values[i]))
+			h.fields = slices.Delete(h.fields, i, i+1)
+			h.values = slices.Delete(h.values, i, i+1)
+		}
+		return
+	}
+	if value, ok := h.h[key]; ok {
+		h.scanIndex = nil
+		h.bytes -= uint64(len(key) + len(value))
+		delete(h.h, key)
+	}
+}
+
+func (h *Hash) Contains(key string) bool {
+	_, ok := h.Get(key)
+	return ok
+}
+
+// GetAll returns a copy of all the fields and values
+func (h *Hash) GetAll() map[string]string {
+	m := make(map[string]string, h.Len())
+	h.each(func(field, value string) {
+		m[field] = value
+	})
+	return m
+}
+
+// each calls fn for each field and value
+func (h *Hash) each(fn func(field, value string)) {
+	if h.enc == EncodingListpack {
+		for i, field := range h.fields {
+			fn(field, h.values[i])
+		}
+		return
+	}
+	for field, value := range h.h {
+		fn(field, value)
+	}
+}
+
+// SetExpireTime sets the expire time of field, and returns false if field doesn't exist
+func (h *Hash) SetExpireTime(field string, expireTime time.Time) bool {
+	if !h.Contains(field) {
+		return false
+	}
+	if h.expires == nil {
+		h.expires = make(map[string]time.Time)
+	}
+	h.expires[field] = expireTime
+	if h.nextExpire.IsZero() || expireTime.Before(h.nextExpire) {
+		h.nextExpire = expireTime
+	}
+	return true
+}
+
+// ExpireTime returns the expire time of field, which is zero if it has none.
+// It returns false if field doesn't exist.
+func (h *Hash) ExpireTime(field string) (time.Time, bool) {
+	if !h.Contains(field) {
+		return time.Time{}, false
+	}
+	return h.expires[field], true
+}
+
+// Persist removes the expire time of field, and returns whether it had one
+func (h *Hash) Persist(field string) bool {
+	if _, ok := h.expires[field]; !ok {
+		return false
+	}
+	delete(h.expires, field)
+	return true
+}
+
+// RemoveExpired removes the fields expired at now, and returns the number of removed fields
+func (h *Hash) RemoveExpired(now time.Time) int {
+	if len(h.expires) == 0 || now.Before(h.nextExpire) {
+		return 0
+	}
+
+	n := 0
+	h.nextExpire = time.Time{}
+	for field, expireTime := range h.expires {
+		if !now.Before(expireTime) {
+			h.Remove(field)
+			n++
+		} else if h.nextExpire.IsZero() || expireTime.Before(h.nextExpire) {
+			h.nextExpire = expireTime
+		}
+	}
+	return n
+}
+
+// HashField is a field of a Hash with its value
+type HashField struct {
+	Field string
+	Value string
+}
+
+// IncrBy increments the integer stored in field by delta, keeping its expire time,
+// and returns the new value. A missing field is treated as 0.
+func (h *Hash) IncrBy(field string, delta int64) (int64, error) {
+	var n int64
+	if v, ok := h.Get(field); ok {
+		var err error
+		if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return 0, ErrNotInteger
+		}
+	}
+	n, err := addInt64(n, delta)
+	if err != nil {
+		return 0, err
+	}
+	h.set(field, strconv.FormatInt(n, 10))
+	return n, nil
+}
+
+// IncrByFloat increments the number stored in field by delta, keeping its expire time,
+// and returns the new value. A missing field is treated as 0.
+func (h *Hash) IncrByFloat(field string, delta float64) (float64, error) {
+	var f float64
+	if v, ok := h.Get(field); ok {
+		var err error
+		if f, err = strconv.ParseFloat(v, 64); err != nil || math.IsNaN(f) {
+			return 0, ErrNotFloat
+		}
+	}
+	f += delta
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, ErrNaNOrInfinity
+	}
+	h.set(field, strconv.FormatFloat(f, 'f', -1, 64))
+	return f, nil
+}
+
+// Keys returns the fields, in insertion order for a listpack
+func (h *Hash) Keys() []string {
+	keys := make([]string, 0, h.Len())
+	h.each(func(field, _ string) {
+		keys = append(keys, field)
+	})
+	return keys
+}
+
+// Values returns the values, in the same order as Keys
+func (h *Hash) Values() []string {
+	values := make([]string, 0, h.Len())
+	h.each(func(_, value string) {
+		values = append(values, value)
+	})
+	return values
+}
+
+// StrLen returns the length of the value of field, or 0 if it doesn't exist
+func (h *Hash) StrLen(field string) int {
+	v, _ := h.Get(field)
+	return len(v)
+}
+
+// Random returns count distinct random fields if count is positive, or -count
+// random fields that may repeat if count is negative.
+func (h *Hash) Random(count int) []HashField {
+	all := make([]HashField, 0, h.Len())
+	h.each(func(field, value string) {
+		all = append(all, HashField{field, value})
+	})
+	return randomSample(all, count)
+}
+
+// Scan iterates over the fields in the order of their hash, starting at cursor, which is 0
+// for the first call. It examines about count fields, returns those matching the glob-style
+// pattern match, if not empty, and the cursor for the next call, which is 0 once done.
+// Fields present during the whole iteration are returned at least once. The order is kept in
+// an index, rebuilt only after fields are added or removed.
+func (h *Hash) Scan(cursor uint64, match string, count int) (uint64, []HashField) {
+	if count <= 0 {
+		count = 10
+	}
+
+	if h.scanIndex == nil {
+		h.scanIndex = make([]scanEntry, 0, h.Len())
+		h.each(func(field, _ string) {
+			h.scanIndex = append(h.scanIndex, scanEntry{scanHash(field), field})
+		})
+		slices.SortFunc(h.scanIndex, func(a, b scanEntry) int {
+			return cmp.Compare(a.sum, b.sum)
+		})
+	}
+	all := h.scanIndex
+
+	i, _ := slices.BinarySearchFunc(all, cursor, func(e scanEntry, cursor uint64) int {
+		return cmp.Compare(e.sum, cursor)
+	})
+	end := len(all)
+	if count < len(all)-i {
+		end = i + count
+	}
+	// don't split fields with the same hash across calls
+	for end < len(all) && end > i && all[end].sum == all[end-1].sum {
+		end++
+	}
+
+	var fields []HashField
+	for _, e := range all[i:end] {
+		if match == "" || util.Match(match, e.field) {
+			value, _ := h.Get(e.field)
+			fields = append(fields, HashField{e.field, value})
+		}
+	}
+	if end == len(all) {
+		return 0, fields
+	}
+	return all[end].sum, fields
+}
+
+// scanHash returns the position of field in the order of Scan, which is never 0
+func scanHash(field string) uint64 {
+	hf := fnv.New64a()
+	hf.Write([]byte(field))
+	return max(hf.Sum64(), 1)
 }
@@ -0,0 +1,256 @@
+package mycache
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+const (
+	// hllP is the number of bits of the hash selecting a register
+	hllP = 14
+	// hllRegisters is the number of registers, for a standard error of 1.04/sqrt(hllRegisters) = 0.81%
+	hllRegisters = 1 << hllP
+	// hllQ is the number of bits of the hash used to count zeros
+	hllQ = 64 - hllP
+	// hllBits is the width of a dense register
+	hllBits = 6
+	// hllDenseSize is the size of the dense registers, plus a byte so that any register spans two bytes
+	hllDenseSize = hllRegisters*hllBits/8 + 1
+)
+
+// HyperLogLogSparseMaxBytes is the size above which a sparse HyperLogLog becomes dense,
+// like hll-sparse-max-bytes in Redis
+var HyperLogLogSparseMaxBytes = 3000
+
+// HyperLogLog estimates the number of distinct elements added to it with a standard error of 0.81%,
+// in at most 12 KiB. While few registers are set, it stores them sparsely.
+type HyperLogLog struct {
+	// sparse holds the set registers as index<<8 | value, sorted by index, while dense is nil
+	sparse []uint32
+	// dense holds 6 bit registers, packed from the least significant bit
+	dense []byte
+	// card caches the cardinality, it's -1 when registers changed since it was computed
+	card int64
+}
+
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{}
+}
+
+func (h *HyperLogLog) Size() uint64 {
+	if h.dense != nil {
+		return hllDenseSize
+	}
+	return uint64(sliceHeaderSize + 4*len(h.sparse))
+}
+
+// Len returns the estimated number of distinct elements, see Count
+func (h *HyperLogLog) Len() int {
+	return int(h.Count())
+}
+
+func (h *HyperLogLog) Type() string {
+	return "HyperLogLog"
+}
+
+// Encoding returns "sparse" or "dense"
+func (h *HyperLogLog) Encoding() string {
+	if h.dense != nil {
+		return "dense"
+	}
+	return "sparse"
+}
+
+// Add adds elements, and returns whether the estimated cardinality may have changed
+func (h *HyperLogLog) Add(elements ...string) bool {
+	changed := false
+	for _, e := range elements {
+		hash := murmurHash64A([]byte(e), 0xadc83b19)
+		index := int(hash & (hllRegisters - 1))
+		// count the zeros of the remaining bits from the least significant one, capped at hllQ
+		count := uint8(bits.TrailingZeros64(hash>>hllP|1<<hllQ)) + 1
+		if h.setMax(index, count) {
+			changed = true
+		}
+	}
+	if changed {
+		h.card = -1
+	}
+	return changed
+}
+
+// Count returns the estimated number of distinct elements
+func (h *HyperLogLog) Count() uint64 {
+	if h.card < 0 {
+		h.card = int64(h.estimate())
+	}
+	return uint64(h.card)
+}
+
+// Merge sets each register to the largest among h and others, so that h estimates their union
+func (h *HyperLogLog) Merge(others ...*HyperLogLog) {
+	for _, o := range others {
+		o.each(func(index int, value uint8) {
+			if h.setMax(index, value) {
+				h.card = -1
+			}
+		})
+	}
+}
+
+// get returns the value of the register at index
+func (h *HyperLogLog) get(index int) uint8 {
+	if h.dense == nil {
+		i, found := h.find(index)
+		if !found {
+			return 0
+		}
+		return uint8(h.sparse[i])
+	}
+	pos := index * hllBits
+	b, fb := pos/8, uint(pos%8)
+	return uint8((uint16(h.dense[b])|uint16(h.dense[b+1])<<8)>>fb) & (1<<hllBits - 1)
+}
+
+// setMax sets the register at index to value if it's larger, and returns whether it changed
+func (h *HyperLogLog) setMax(index int, value uint8) bool {
+	if h.get(index) >= value {
+		return false
+	}
+
+	if h.dense == nil {
+		i, found := h.find(index)
+		entry := uint32(index)<<8 | uint32(value)
+		if found {
+			h.sparse[i] = entry
+			return true
+		}
+		if 4*(len(h.sparse)+1) <= HyperLogLogSparseMaxBytes {
+			h.sparse = slices.Insert(h.sparse, i, entry)
+			return true
+		}
+		h.toDense()
+	}
+
+	pos := index * hllBits
+	b, fb := pos/8, uint(pos%8)
+	word := uint16(h.dense[b]) | uint16(h.dense[b+1])<<8
+	word = word&^((1<<hllBits-1)<<fb) | uint16(value)<<fb
+	h.dense[b], h.dense[b+1] = byte(word), byte(word>>8)
+	return true
+}
+
+// find returns the position of the register at index in sparse, and whether it's set
+func (h *HyperLogLog) find(index int) (int, bool) {
+	return slices.BinarySearchFunc(h.sparse, uint32(index), func(e, index uint32) int {
+		return int(e>>8) - int(index)
+	})
+}
+
+func (h *HyperLogLog) toDense() {
+	sparse := h.sparse
+	h.sparse = nil
+	h.dense = make([]byte, hllDenseSize)
+	for _, e := range sparse {
+		h.setMax(int(e>>8), uint8(e))
+	}
+}
+
+// each calls fn with the index and value of each register that is set
+func (h *HyperLogLog) each(fn func(index int, value uint8)) {
+	if h.dense == nil {
+		for _, e := range h.sparse {
+			fn(int(e>>8), uint8(e))
+		}
+		return
+	}
+	for i := 0; i < hllRegisters; i++ {
+		if v := h.get(i); v != 0 {
+			fn(i, v)
+		}
+	}
+}
+
+// estimate computes the cardinality with the improved estimator of Otmar Ertl,
+// "New cardinality estimation algorithms for HyperLogLog sketches", also used by Redis.
+func (h *HyperLogLog) estimate() float64 {
+	// histogram of the register values
+	var reg [hllQ + 2]int
+	set := 0
+	h.each(func(_ int, value uint8) {
+		reg[value]++
+		set++
+	})
+	reg[0] = hllRegisters - set
+
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(reg[hllQ+1]))/m)
+	for k := hllQ; k >= 1; k-- {
+		z += float64(reg[k])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(reg[0])/m)
+	return math.Round(0.5 / math.Ln2 * m * m / z)
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
+
+// murmurHash64A is the 64 bit MurmurHash2 of Austin Appleby, as used by Redis for HyperLogLog
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+	h := seed ^ uint64(len(key))*m
+
+	for ; len(key) >= 8; key = key[8:] {
+		k := binary.LittleEndian.Uint64(key)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+
+	if len(key) > 0 {
+		for i := len(key) - 1; i >= 0; i-- {
+			h ^= uint64(key[i]) << (8 * i)
+		}
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
@@ -1,69 +1,354 @@
 package mycache
 
-import "errors"
+// listChunkSize is the number of elements held by each chunk of a List
+const listChunkSize = 64
 
+// listChunk is a node of the doubly linked list of chunks backing a List.
+// Its elements are items[start:end], so that it can grow at both ends.
+type listChunk struct {
+	items      [listChunkSize]string
+	start, end int
+	prev, next *listChunk
+}
+
+func (c *listChunk) len() int {
+	return c.end - c.start
+}
+
+// List is a deque of strings, stored as a linked list of fixed size chunks like Redis' quicklist.
+// Pushing and popping at both ends is O(1), and accessing by index is O(n/listChunkSize).
 type List struct {
-	slice []string
+	head, tail *listChunk
+	length     int
+	// size is the total length of the elements
+	size uint64
 }
 
 func NewEmptyList() *List {
-	return &List{slice: make([]string, 0)}
+	return &List{}
 }
 
 func NewList(strs []string) *List {
-	list := &List{slice: make([]string, len(strs))}
-	for i := 0; i < len(strs); i++ {
-		list.slice[i] = strs[i]
+	list := &List{}
+	for _, s := range strs {
+		list.Add(s)
 	}
 	return list
 }
 
 func (l *List) Size() uint64 {
-	var size uint64 = 0
-	for _, v := range l.slice {
-		size += uint64(len(v))
-	}
-	return size
+	return l.size
 }
 
 func (l *List) Len() int {
-	return len(l.slice)
+	return l.length
 }
 
 func (l *List) Type() string {
 	return "List"
 }
 
+// index converts i to an index from the head, negative indexes counting from the tail.
+// It returns false if i is out of range.
+func (l *List) index(i int) (int, bool) {
+	if i < 0 {
+		i += l.length
+	}
+	return i, i >= 0 && i < l.length
+}
+
+// locate returns the chunk holding the element at index i, which must be in range,
+// and the position of the element in the chunk's items.
+func (l *List) locate(i int) (*listChunk, int) {
+	if i < l.length/2 {
+		c := l.head
+		for i >= c.len() {
+			i -= c.len()
+			c = c.next
+		}
+		return c, c.start + i
+	}
+
+	i = l.length - 1 - i
+	c := l.tail
+	for i >= c.len() {
+		i -= c.len()
+		c = c.prev
+	}
+	return c, c.end - 1 - i
+}
+
+// Get returns the element at index i. Negative indexes count from the tail.
 func (l *List) Get(i int) (string, error) {
-	if i >= len(l.slice) {
-		err := errors.New("index out of bounds")
-		return "", err
+	i, ok := l.index(i)
+	if !ok {
+		return "", ErrIndexOutOfRange
 	}
-	return l.slice[i], nil
+	c, j := l.locate(i)
+	return c.items[j], nil
 }
 
+// GetAll returns a copy of the elements
 func (l *List) GetAll() []string {
-	return l.slice
+	strs := make([]string, 0, l.length)
+	for c := l.head; c != nil; c = c.next {
+		strs = append(strs, c.items[c.start:c.end]...)
+	}
+	return strs
 }
 
+// Set replaces the element at index i. Negative indexes count from the tail.
 func (l *List) Set(i int, s string) error {
-	if i >= len(l.slice) {
-		err := errors.New("index out of bounds")
-		return err
+	i, ok := l.index(i)
+	if !ok {
+		return ErrIndexOutOfRange
 	}
-	l.slice[i] = s
+	c, j := l.locate(i)
+	l.size += uint64(len(s)) - uint64(len(c.items[j]))
+	c.items[j] = s
 	return nil
 }
 
+// Add appends s to the tail of the list
 func (l *List) Add(s string) {
-	l.slice = append(l.slice, s)
+	if l.tail == nil || l.tail.end == listChunkSize {
+		l.linkAfter(l.tail, &listChunk{})
+	}
+	l.tail.items[l.tail.end] = s
+	l.tail.end++
+	l.length++
+	l.size += uint64(len(s))
 }
 
+// PushFront inserts s at the head of the list
+func (l *List) PushFront(s string) {
+	if l.head == nil || l.head.start == 0 {
+		l.linkBefore(l.head, &listChunk{start: listChunkSize, end: listChunkSize})
+	}
+	l.head.start--
+	l.head.items[l.head.start] = s
+	l.length++
+	l.size += uint64(len(s))
+}
+
+// PopFront removes and returns the first element
+func (l *List) PopFront() (string, bool) {
+	if l.length == 0 {
+		return "", false
+	}
+	c := l.head
+	s := c.items[c.start]
+	c.items[c.start] = ""
+	c.start++
+	l.removed(c, s)
+	return s, true
+}
+
+// PopBack removes and returns the last element
+func (l *List) PopBack() (string, bool) {
+	if l.length == 0 {
+		return "", false
+	}
+	c := l.tail
+	c.end--
+	s := c.items[c.end]
+	c.items[c.end] = ""
+	l.removed(c, s)
+	return s, true
+}
+
+// Remove removes the element at index i. Negative indexes count from the tail.
 func (l *List) Remove(i int) error {
-	if i >= len(l.slice) {
-		err := errors.New("index out of bounds")
-		return err
+	i, ok := l.index(i)
+	if !ok {
+		return ErrIndexOutOfRange
+	}
+	c, j := l.locate(i)
+	s := c.items[j]
+	// shift the shorter side of the chunk
+	if j-c.start < c.end-1-j {
+		copy(c.items[c.start+1:j+1], c.items[c.start:j])
+		c.items[c.start] = ""
+		c.start++
+	} else {
+		copy(c.items[j:], c.items[j+1:c.end])
+		c.end--
+		c.items[c.end] = ""
+	}
+	l.removed(c, s)
+	return nil
+}
+
+// removed accounts for s removed from c, and unlinks c once it's empty
+func (l *List) removed(c *listChunk, s string) {
+	l.length--
+	l.size -= uint64(len(s))
+	if c.len() == 0 {
+		l.unlink(c)
+	}
+}
+
+// Insert inserts s at index i, shifting the following elements.
+func (l *List) Insert(i int, s string) error {
+	if i < 0 || i > l.length {
+		return ErrIndexOutOfRange
+	}
+	if i == 0 {
+		l.PushFront(s)
+		return nil
 	}
-	l.slice = append(l.slice[:i], l.slice[i+1:]...)
+	if i == l.length {
+		l.Add(s)
+		return nil
+	}
+
+	c, j := l.locate(i)
+	if c.start == 0 && c.end == listChunkSize {
+		// split the full chunk, moving its second half to a new chunk
+		half := listChunkSize / 2
+		next := &listChunk{end: listChunkSize - half}
+		copy(next.items[:], c.items[half:])
+		clear(c.items[half:])
+		c.end = half
+		l.linkAfter(c, next)
+		if j >= half {
+			c, j = next, j-half
+		}
+	}
+
+	if c.end < listChunkSize {
+		copy(c.items[j+1:c.end+1], c.items[j:c.end])
+		c.end++
+	} else {
+		copy(c.items[c.start-1:j-1], c.items[c.start:j])
+		c.start--
+		j--
+	}
+	c.items[j] = s
+	l.length++
+	l.size += uint64(len(s))
 	return nil
 }
+
+// linkAfter inserts chunk c after prev, or at the head if prev is nil
+func (l *List) linkAfter(prev, c *listChunk) {
+	c.prev = prev
+	if prev == nil {
+		c.next = l.head
+		l.head = c
+	} else {
+		c.next = prev.next
+		prev.next = c
+	}
+	if c.next == nil {
+		l.tail = c
+	} else {
+		c.next.prev = c
+	}
+}
+
+// linkBefore inserts chunk c before next, or at the tail if next is nil
+func (l *List) linkBefore(next, c *listChunk) {
+	if next == nil {
+		l.linkAfter(l.tail, c)
+	} else {
+		l.linkAfter(next.prev, c)
+	}
+}
+
+func (l *List) unlink(c *listChunk) {
+	if c.prev == nil {
+		l.head = c.next
+	} else {
+		c.prev.next = c.next
+	}
+	if c.next == nil {
+		l.tail = c.prev
+	} else {
+		c.next.prev = c.prev
+	}
+	c.prev, c.next = nil, nil
+}
+
+// Range returns the elements between start and stop, both inclusive.
+// Negative indexes count from the end of the list.
+func (l *List) Range(start, stop int) []string {
+	lo, hi, ok := normalizeRange(start, stop, l.length)
+	if !ok {
+		return []string{}
+	}
+	strs := make([]string, 0, hi-lo)
+	c, j := l.locate(lo)
+	for len(strs) < hi-lo {
+		if j == c.end {
+			c = c.next
+			j = c.start
+		}
+		n := min(c.end-j, hi-lo-len(strs))
+		strs = append(strs, c.items[j:j+n]...)
+		j += n
+	}
+	return strs
+}
+
+// Trim keeps only the elements between start and stop, both inclusive.
+func (l *List) Trim(start, stop int) {
+	lo, hi, ok := normalizeRange(start, stop, l.length)
+	if !ok {
+		lo, hi = 0, 0
+	}
+	for n := l.length - hi; n > 0; n-- {
+		l.PopBack()
+	}
+	for ; lo > 0; lo-- {
+		l.PopFront()
+	}
+}
+
+// Index returns the index of the first occurrence of s, or -1 if s is not in the list.
+func (l *List) Index(s string) int {
+	i := 0
+	for c := l.head; c != nil; c = c.next {
+		for _, v := range c.items[c.start:c.end] {
+			if v == s {
+				return i
+			}
+			i++
+		}
+	}
+	return -1
+}
+
+// RemoveValue removes the first count occurrences of s from the head if count > 0,
+// from the tail if count < 0, or all of them if count == 0. It returns the number removed.
+func (l *List) RemoveValue(count int, s string) int {
+	strs := l.GetAll()
+	removed := 0
+	kept := make([]string, 0, len(strs))
+	if count >= 0 {
+		for _, v := range strs {
+			if v == s && (count == 0 || removed < count) {
+				removed++
+				continue
+			}
+			kept = append(kept, v)
+		}
+	} else {
+		for i := len(strs) - 1; i >= 0; i-- {
+			v := strs[i]
+			if v == s && removed < -count {
+				removed++
+				continue
+			}
+			kept = append(kept, v)
+		}
+		for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+			kept[i], kept[j] = kept[j], kept[i]
+		}
+	}
+
+	if removed > 0 {
+		*l = *NewList(kept)
+	}
+	return removed
+}
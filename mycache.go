@@ -2,6 +2,7 @@ package mycache
 
 import (
 	"container/list"
+	"log"
 	"sync"
 	"time"
 )
@@ -55,6 +56,7 @@ func (c *MyCache) Use(name string) *database {
 			dbName:  name,
 			cache:   make(map[string]*list.Element),
 			list:    list.New(),
+			waiters: make(map[string]*list.List),
 		}
 		c.databases[name] = db
 	}
@@ -63,7 +65,9 @@ func (c *MyCache) Use(name string) *database {
 		go func() {
 			for {
 				time.Sleep(c.cleanInterval)
-				db.RemoveExpired()
+				if err := db.RemoveExpired(); err != nil {
+					log.Printf("mycache: %s: %v", db.dbName, err)
+				}
 			}
 		}()
 	}
@@ -1,6 +1,15 @@
 package mycache
 
 import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -167,3 +176,1523 @@ func TestZset(t *testing.T) {
 		t.Errorf("got %s, expect 23", strs[1])
 	}
 }
+
+func TestStringCommands(t *testing.T) {
+	db := Default().Use("test")
+	n, err := db.IncrBy("counter", 5)
+	if err != nil || n != 5 {
+		t.Errorf("got %d %v, expect 5 nil", n, err)
+	}
+	n, _ = db.Decr("counter")
+	if n != 4 {
+		t.Errorf("got %d, expect 4", n)
+	}
+
+	db.SetValue("lbw", NewString("hello"))
+	if _, err := db.Incr("lbw"); err != ErrNotInteger {
+		t.Errorf("got %v, expect %v", err, ErrNotInteger)
+	}
+	length, _ := db.Append("lbw", " world")
+	if length != 11 {
+		t.Errorf("got %d, expect 11", length)
+	}
+	s, _ := db.GetRange("lbw", -5, -1)
+	if s != "world" {
+		t.Errorf("got %s, expect world", s)
+	}
+	length, _ = db.SetRange("lbw", 6, "there")
+	if v, _ := db.GetString("lbw"); length != 11 || v.ToString() != "hello there" {
+		t.Errorf("got %d %s, expect 11 hello there", length, v.ToString())
+	}
+	if _, err := db.SetRange("lbw", 1<<40, "x"); err != ErrOutOfMemory {
+		t.Errorf("got %v, expect %v", err, ErrOutOfMemory)
+	}
+	if _, err := db.SetRange("lbw", math.MaxInt, "x"); err != ErrOffsetOutOfRange {
+		t.Errorf("got %v, expect %v", err, ErrOffsetOutOfRange)
+	}
+
+	old, ok, _ := db.GetAndSet("lbw", "23")
+	if !ok || old != "hello there" {
+		t.Errorf("got %s %t, expect hello there true", old, ok)
+	}
+	if ok, _ := db.SetNX("lbw", "25"); ok {
+		t.Errorf("got true, expect false")
+	}
+}
+
+func TestListCommands(t *testing.T) {
+	db := Default().Use("test")
+	_, _ = db.RPush("lbw", "b", "c")
+	n, _ := db.LPush("lbw", "a")
+	if n != 3 {
+		t.Errorf("got %d, expect 3", n)
+	}
+	n, _ = db.LInsertAfter("lbw", "b", "x")
+	if n != 4 {
+		t.Errorf("got %d, expect 4", n)
+	}
+	strs, _ := db.LRange("lbw", 0, -1)
+	if strings.Join(strs, ",") != "a,b,x,c" {
+		t.Errorf("got %v, expect [a b x c]", strs)
+	}
+	if s, ok, _ := db.LIndex("lbw", -2); !ok || s != "x" {
+		t.Errorf("got %s %t, expect x true", s, ok)
+	}
+	if err := db.LSet("lbw", 4, "y"); err != ErrIndexOutOfRange {
+		t.Errorf("got %v, expect %v", err, ErrIndexOutOfRange)
+	}
+	if err := db.LSet("lbw-none", 0, "y"); err != ErrNotFound {
+		t.Errorf("got %v, expect %v", err, ErrNotFound)
+	}
+
+	removed, _ := db.LRem("lbw", 0, "x")
+	if removed != 1 {
+		t.Errorf("got %d, expect 1", removed)
+	}
+	_ = db.LTrim("lbw", 1, -1)
+	s, ok, _ := db.LPop("lbw")
+	if !ok || s != "b" {
+		t.Errorf("got %s %t, expect b true", s, ok)
+	}
+	s, _, _ = db.RPop("lbw")
+	if s != "c" {
+		t.Errorf("got %s, expect c", s)
+	}
+	if _, ok := db.Get("lbw"); ok {
+		t.Errorf("empty list should be removed")
+	}
+}
+
+func TestHashCommands(t *testing.T) {
+	db := Default().Use("test")
+	isNew, _ := db.HSet("lbw", "age", "23")
+	if !isNew {
+		t.Errorf("got false, expect true")
+	}
+	n, _ := db.HIncrBy("lbw", "age", 2)
+	if n != 25 {
+		t.Errorf("got %d, expect 25", n)
+	}
+	if ok, _ := db.HSetNX("lbw", "age", "30"); ok {
+		t.Errorf("got true, expect false")
+	}
+
+	values, _ := db.HMGet("lbw", "age", "gender")
+	if *values[0] != "25" || values[1] != nil {
+		t.Errorf("got %v, expect [25 nil]", values)
+	}
+	all, _ := db.HGetAll("lbw")
+	if len(all) != 1 || all["age"] != "25" {
+		t.Errorf("got %v, expect map[age:25]", all)
+	}
+}
+
+func TestSetCommands(t *testing.T) {
+	db := Default().Use("test")
+	n, _ := db.SAdd("a", "1", "2", "3", "3")
+	if n != 3 {
+		t.Errorf("got %d, expect 3", n)
+	}
+	_, _ = db.SAdd("b", "2", "3", "4")
+
+	diff, _ := db.SDiff("a", "b")
+	if len(diff) != 1 || diff[0] != "1" {
+		t.Errorf("got %v, expect [1]", diff)
+	}
+	n, _ = db.SInterStore("c", "a", "b")
+	if n != 2 {
+		t.Errorf("got %d, expect 2", n)
+	}
+
+	moved, _ := db.SMove("a", "b", "1")
+	if card, _ := db.SCard("b"); !moved || card != 4 {
+		t.Errorf("got %t %d, expect true 4", moved, card)
+	}
+	if _, err := db.SRandMember("b", math.MinInt); err != ErrOutOfMemory {
+		t.Errorf("got %v, expect %v", err, ErrOutOfMemory)
+	}
+	if members := NewSet(nil).Random(-3); len(members) != 0 {
+		t.Errorf("got %v, expect none", members)
+	}
+	if members, _ := db.SRandMember("b", -10); len(members) != 10 {
+		t.Errorf("got %d members, expect 10", len(members))
+	}
+	m, ok, _ := db.SPop("c")
+	if !ok || (m != "2" && m != "3") {
+		t.Errorf("got %s %t, expect 2 or 3", m, ok)
+	}
+}
+
+func TestWrongType(t *testing.T) {
+	db := Default().Use("test")
+	db.SetValue("lbw", NewString("23"))
+
+	var wrongType *WrongTypeError
+	if _, err := db.LPush("lbw", "a"); !errors.As(err, &wrongType) {
+		t.Errorf("got %v, expect WrongTypeError", err)
+	}
+	if _, err := db.SAdd("lbw", "a"); !errors.As(err, &wrongType) {
+		t.Errorf("got %v, expect WrongTypeError", err)
+	}
+	if wrongType.Actual != "String" || wrongType.Expected != "Set" {
+		t.Errorf("got %s %s, expect String Set", wrongType.Actual, wrongType.Expected)
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	c := New(16, 0, DefaultPersistPath)
+	db := c.Use("test")
+	if _, err := db.FetchString("lbw"); err != ErrNotFound {
+		t.Errorf("got %v, expect %v", err, ErrNotFound)
+	}
+
+	_ = db.SetValue("lbw", NewString("23"))
+	if _, err := db.FetchList("lbw"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("got %v, expect %v", err, ErrWrongType)
+	}
+
+	_ = db.SetValueAndExpireTime("old", NewString("23"), time.Now().Add(-time.Second))
+	if _, err := db.Fetch("old"); err != ErrExpired {
+		t.Errorf("got %v, expect %v", err, ErrExpired)
+	}
+
+	if err := db.SetValue("big", NewString("this value is too large")); err != ErrOutOfMemory {
+		t.Errorf("got %v, expect %v", err, ErrOutOfMemory)
+	}
+	if _, err := db.FetchString("lbw"); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+
+	list := NewList([]string{"foo"})
+	if _, err := list.Get(1); err != ErrIndexOutOfRange {
+		t.Errorf("got %v, expect %v", err, ErrIndexOutOfRange)
+	}
+}
+
+func TestWriteTooLarge(t *testing.T) {
+	db := New(1024, 0, DefaultPersistPath).Use("test")
+	big := strings.Repeat("x", 2048)
+
+	db.RPush("list", "a", "b")
+	if _, err := db.RPush("list", big); err != ErrOutOfMemory {
+		t.Errorf("got %v, expect %v", err, ErrOutOfMemory)
+	}
+	if n, _ := db.LLen("list"); n != 2 {
+		t.Errorf("got %d, expect 2", n)
+	}
+	db.HSet("hash", "f", "v")
+	if _, err := db.HSet("hash", "g", big); err != ErrOutOfMemory {
+		t.Errorf("got %v, expect %v", err, ErrOutOfMemory)
+	}
+	if v, ok, _ := db.HGet("hash", "f"); !ok || v != "v" {
+		t.Errorf("got %s %t, expect v", v, ok)
+	}
+	db.SAdd("set", "1", "2")
+	if _, err := db.SAdd("set", big); err != ErrOutOfMemory {
+		t.Errorf("got %v, expect %v", err, ErrOutOfMemory)
+	}
+	if n, _ := db.SCard("set"); n != 2 {
+		t.Errorf("got %d, expect 2", n)
+	}
+	if _, err := db.SAdd("set-none", big); err != ErrOutOfMemory || db.Contains("set-none") {
+		t.Errorf("got %v, expect %v and no key", err, ErrOutOfMemory)
+	}
+	db.SetValue("str", NewString("abc"))
+	if _, err := db.Append("str", big); err != ErrOutOfMemory {
+		t.Errorf("got %v, expect %v", err, ErrOutOfMemory)
+	}
+	if s, _ := db.GetString("str"); s.ToString() != "abc" {
+		t.Errorf("got %s, expect abc", s.ToString())
+	}
+}
+
+func TestSMoveEvictsSource(t *testing.T) {
+	src := NewSet(strings.Fields("1 2 3 4 5 6 7 8 9 10"))
+	members := make([]string, 200)
+	for i := range members {
+		members[i] = "m" + strconv.Itoa(i)
+	}
+	dst := NewSet(members)
+
+	// dst can take the member, but only by evicting src
+	db := New(src.Size()+dst.Size()+20, 0, DefaultPersistPath).Use("test")
+	db.SetValue("src", src)
+	db.SetValue("dst", dst)
+	if moved, err := db.SMove("src", "dst", "1"); !moved || err != nil {
+		t.Errorf("got %t %v, expect true nil", moved, err)
+	}
+	if _, ok := db.Get("src"); ok || db.size != dst.Size() {
+		t.Errorf("got size %d, expect %d", db.size, dst.Size())
+	}
+}
+
+func TestZsetCommands(t *testing.T) {
+	db := Default().Use("test")
+	n, _ := db.ZAdd("board", ZAddOptions{}, ZMember{10, "b"}, ZMember{10, "a"}, ZMember{5, "c"})
+	if n != 3 {
+		t.Errorf("got %d, expect 3", n)
+	}
+	zset, _ := db.GetZset("board")
+	if strs := zset.GetRange(10, 11, 1); len(strs) != 2 || strs[0] != "a" {
+		t.Errorf("got %v, expect [a b]", strs)
+	}
+
+	n, _ = db.ZAdd("board", ZAddOptions{GT: true, CH: true}, ZMember{3, "c"}, ZMember{12, "b"})
+	if n != 1 {
+		t.Errorf("got %d, expect 1", n)
+	}
+	if _, err := db.ZAdd("board", ZAddOptions{NX: true, XX: true}); err != ErrIncompatibleOptions {
+		t.Errorf("got %v, expect %v", err, ErrIncompatibleOptions)
+	}
+	if _, ok, _ := db.ZAddIncr("board", ZAddOptions{NX: true}, 1, "a"); ok {
+		t.Errorf("got true, expect false")
+	}
+
+	score, _ := db.ZIncrBy("board", 2.5, "c")
+	if score != 7.5 {
+		t.Errorf("got %v, expect 7.5", score)
+	}
+	score, ok, _ := db.ZScore("board", "b")
+	if !ok || score != 12 {
+		t.Errorf("got %v %t, expect 12 true", score, ok)
+	}
+
+	n, _ = db.ZRem("board", "a", "x")
+	if card, _ := db.ZCard("board"); n != 1 || card != 2 {
+		t.Errorf("got %d %d, expect 1 2", n, card)
+	}
+}
+
+func TestZsetRange(t *testing.T) {
+	db := Default().Use("test")
+	_, _ = db.ZAdd("board", ZAddOptions{},
+		ZMember{1, "a"}, ZMember{2, "b"}, ZMember{2, "c"}, ZMember{3.5, "d"}, ZMember{5, "e"})
+
+	min, _ := ParseScoreBound("(1")
+	max, _ := ParseScoreBound("+inf")
+	members, _ := db.ZRangeByScore("board", min, max, 1, 2)
+	if len(members) != 2 || members[0].Member != "c" || members[1].Member != "d" {
+		t.Errorf("got %v, expect [c d]", members)
+	}
+	if members, _ := db.ZRangeByScore("board", min, max, 1, math.MaxInt); len(members) != 3 {
+		t.Errorf("got %v, expect [c d e]", members)
+	}
+	if members, _ := db.ZRangeByScore("board", min, max, math.MaxInt, 1); len(members) != 0 {
+		t.Errorf("got %v, expect []", members)
+	}
+	members, _ = db.ZRevRangeByScore("board", ScoreBound{Score: 3.5}, ScoreBound{Score: 2}, 0, -1)
+	if len(members) != 3 || members[0].Member != "d" || members[2].Member != "b" {
+		t.Errorf("got %v, expect [d c b]", members)
+	}
+	if n, _ := db.ZCount("board", ScoreBound{Score: 2}, ScoreBound{Score: 5, Exclusive: true}); n != 3 {
+		t.Errorf("got %d, expect 3", n)
+	}
+
+	members, _ = db.ZRange("board", -2, -1)
+	if len(members) != 2 || members[0].Member != "d" || members[1].Score != 5 {
+		t.Errorf("got %v, expect [d e]", members)
+	}
+	members, _ = db.ZRevRange("board", 0, 1)
+	if len(members) != 2 || members[0].Member != "e" || members[1].Member != "d" {
+		t.Errorf("got %v, expect [e d]", members)
+	}
+
+	rank, ok, _ := db.ZRank("board", "c")
+	if !ok || rank != 2 {
+		t.Errorf("got %d %t, expect 2 true", rank, ok)
+	}
+	if rank, _, _ := db.ZRevRank("board", "c"); rank != 2 {
+		t.Errorf("got %d, expect 2", rank)
+	}
+	if _, err := ParseScoreBound("abc"); err != ErrNotFloat {
+		t.Errorf("got %v, expect %v", err, ErrNotFloat)
+	}
+}
+
+func TestZsetStore(t *testing.T) {
+	db := Default().Use("test")
+	_, _ = db.ZAdd("a", ZAddOptions{}, ZMember{1, "x"}, ZMember{2, "y"}, ZMember{3, "z"})
+	_, _ = db.ZAdd("b", ZAddOptions{}, ZMember{10, "y"}, ZMember{20, "z"})
+	_, _ = db.SAdd("c", "z")
+
+	n, _ := db.ZUnionStore("union", []string{"a", "b"}, ZStoreOptions{Weights: []float64{1, 2}})
+	if score, _, _ := db.ZScore("union", "z"); n != 3 || score != 43 {
+		t.Errorf("got %d %v, expect 3 43", n, score)
+	}
+	n, _ = db.ZInterStore("inter", []string{"a", "b", "c"}, ZStoreOptions{Aggregate: AggregateMax})
+	if score, _, _ := db.ZScore("inter", "z"); n != 1 || score != 20 {
+		t.Errorf("got %d %v, expect 1 20", n, score)
+	}
+	if _, err := db.ZUnionStore("union", []string{"a", "b"}, ZStoreOptions{Weights: []float64{1}}); err != ErrInvalidWeights {
+		t.Errorf("got %v, expect %v", err, ErrInvalidWeights)
+	}
+
+	members, _ := db.ZDiff("a", "b")
+	if len(members) != 1 || members[0].Member != "x" {
+		t.Errorf("got %v, expect [x]", members)
+	}
+
+	members, _ = db.ZPopMax("a", 2)
+	if len(members) != 2 || members[0].Member != "z" || members[1].Member != "y" {
+		t.Errorf("got %v, expect [z y]", members)
+	}
+	members, _ = db.ZPopMin("a", 5)
+	if _, ok := db.Get("a"); len(members) != 1 || ok {
+		t.Errorf("got %v, expect [x] and a removed", members)
+	}
+}
+
+func TestZsetLex(t *testing.T) {
+	db := Default().Use("test")
+	for _, m := range []string{"apple", "apricot", "banana", "blueberry", "cherry"} {
+		_, _ = db.ZAdd("fruits", ZAddOptions{}, ZMember{0, m})
+	}
+
+	min, _ := ParseLexBound("[ap")
+	max, _ := ParseLexBound("(b")
+	strs, _ := db.ZRangeByLex("fruits", min, max, 0, -1)
+	if strings.Join(strs, ",") != "apple,apricot" {
+		t.Errorf("got %v, expect [apple apricot]", strs)
+	}
+	if n, _ := db.ZLexCount("fruits", LexBound{Value: "b"}, MaxLex); n != 3 {
+		t.Errorf("got %d, expect 3", n)
+	}
+	if n, _ := db.ZRemRangeByLex("fruits", MinLex, LexBound{Value: "banana"}); n != 3 {
+		t.Errorf("got %d, expect 3", n)
+	}
+	strs, _ = db.ZRangeByLex("fruits", MinLex, MaxLex, 0, -1)
+	if strings.Join(strs, ",") != "blueberry,cherry" {
+		t.Errorf("got %v, expect [blueberry cherry]", strs)
+	}
+	if _, err := ParseLexBound("b"); err != ErrInvalidLexBound {
+		t.Errorf("got %v, expect %v", err, ErrInvalidLexBound)
+	}
+}
+
+func TestZsetRemoveRange(t *testing.T) {
+	db := Default().Use("test")
+	for i := 0; i < 10; i++ {
+		_, _ = db.ZAdd("board", ZAddOptions{}, ZMember{float64(i), strconv.Itoa(i)})
+	}
+
+	if n, _ := db.ZRemRangeByScore("board", ScoreBound{Score: 2, Exclusive: true}, ScoreBound{Score: 5}); n != 3 {
+		t.Errorf("got %d, expect 3", n)
+	}
+	if n, _ := db.ZRemRangeByRank("board", -2, -1); n != 2 {
+		t.Errorf("got %d, expect 2", n)
+	}
+	members, _ := db.ZRevRange("board", 0, -1)
+	if len(members) != 5 || members[0].Member != "7" || members[4].Member != "0" {
+		t.Errorf("got %v, expect [7 6 2 1 0]", members)
+	}
+	if _, ok, _ := db.ZScore("board", "8"); ok {
+		t.Errorf("removed member still has a score")
+	}
+}
+
+func TestBlockingList(t *testing.T) {
+	db := Default().Use("test")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := db.BLPop(ctx, "bq1", "bq2"); err != context.DeadlineExceeded {
+		t.Errorf("got %v, expect %v", err, context.DeadlineExceeded)
+	}
+
+	// waiters are served in FIFO order
+	results := []chan string{make(chan string, 1), make(chan string, 1)}
+	for i := 0; i < 2; i++ {
+		go func(results chan string) {
+			key, s, err := db.BLPop(context.Background(), "bq1", "bq2")
+			if err != nil {
+				t.Error(err)
+			}
+			results <- key + ":" + s
+		}(results[i])
+		for {
+			db.mu.RLock()
+			n := 0
+			if q := db.waiters["bq2"]; q != nil {
+				n = q.Len()
+			}
+			db.mu.RUnlock()
+			if n == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	n, _ := db.RPush("bq2", "a", "b", "c")
+	if n != 3 {
+		t.Errorf("got %d, expect 3", n)
+	}
+	if r := <-results[0]; r != "bq2:a" {
+		t.Errorf("got %s, expect bq2:a", r)
+	}
+	if r := <-results[1]; r != "bq2:b" {
+		t.Errorf("got %s, expect bq2:b", r)
+	}
+	if n, _ := db.LLen("bq2"); n != 1 {
+		t.Errorf("got %d, expect 1", n)
+	}
+
+	s, err := db.BLMove(context.Background(), "bq2", "bq3", Right, Left)
+	if err != nil || s != "c" {
+		t.Errorf("got %s %v, expect c", s, err)
+	}
+	if _, ok := db.Get("bq2"); ok {
+		t.Errorf("empty list should be removed")
+	}
+	s, ok, _ := db.LMove("bq3", "bq3", Left, Right)
+	if !ok || s != "c" {
+		t.Errorf("got %s %t, expect c true", s, ok)
+	}
+
+	db.SetValue("bqs", NewString("x"))
+	if _, _, err := db.BRPop(context.Background(), "bqs"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("got %v, expect %v", err, ErrWrongType)
+	}
+	if _, err := db.BLMove(context.Background(), "bq3", "bqs", Left, Left); !errors.Is(err, ErrWrongType) {
+		t.Errorf("got %v, expect %v", err, ErrWrongType)
+	}
+	if n, _ := db.LLen("bq3"); n != 1 {
+		t.Errorf("got %d, expect 1", n)
+	}
+}
+
+func TestListChunks(t *testing.T) {
+	l := NewEmptyList()
+	var strs []string
+	for i := 0; i < 3*listChunkSize; i++ {
+		l.PushFront(strconv.Itoa(i))
+		strs = append([]string{strconv.Itoa(i)}, strs...)
+	}
+	// insert in the middle of a full chunk
+	_ = l.Insert(listChunkSize+1, "x")
+	strs = append(strs[:listChunkSize+1], append([]string{"x"}, strs[listChunkSize+1:]...)...)
+	if got := l.GetAll(); strings.Join(got, ",") != strings.Join(strs, ",") {
+		t.Errorf("got %v, expect %v", got, strs)
+	}
+
+	if s, _ := l.Get(-1); s != "0" {
+		t.Errorf("got %s, expect 0", s)
+	}
+	_ = l.Set(-1, "last")
+	_ = l.Remove(-2)
+	if s, _ := l.Get(-2); s != "2" {
+		t.Errorf("got %s, expect 2", s)
+	}
+	if _, err := l.Get(-l.Len() - 1); err != ErrIndexOutOfRange {
+		t.Errorf("got %v, expect %v", err, ErrIndexOutOfRange)
+	}
+
+	for l.Len() > 1 {
+		l.PopBack()
+	}
+	if s, _ := l.PopFront(); s != strconv.Itoa(3*listChunkSize-1) {
+		t.Errorf("got %s, expect %d", s, 3*listChunkSize-1)
+	}
+	if l.Size() != 0 || l.head != nil || l.tail != nil {
+		t.Errorf("empty list should have no chunks")
+	}
+}
+
+func TestQueue(t *testing.T) {
+	db := Default().Use("test")
+	db.SetValue("q", NewQueue(QueueOptions{
+		VisibilityTimeout: 20 * time.Millisecond,
+		MaxDeliveries:     2,
+		DeadLetterKey:     "q-dead",
+	}))
+	ids, _ := db.QPush("q", "a", "b")
+	if len(ids) != 2 {
+		t.Errorf("got %d, expect 2", len(ids))
+	}
+
+	m, ok, _ := db.QReserve("q")
+	if !ok || m.Body != "a" || m.Deliveries != 1 {
+		t.Errorf("got %v %t, expect a", m, ok)
+	}
+	m2, _, _ := db.QReserve("q")
+	if ok, _ := db.QAck("q", m2.ID); !ok {
+		t.Errorf("got %t, expect true", ok)
+	}
+	if _, ok, _ := db.QReserve("q"); ok {
+		t.Errorf("reserved message should be invisible")
+	}
+
+	// a is delivered again once its visibility timeout elapses
+	time.Sleep(30 * time.Millisecond)
+	if ready, inflight, _ := db.QLen("q"); ready != 1 || inflight != 0 {
+		t.Errorf("got %d %d, expect 1 0", ready, inflight)
+	}
+	m, _, _ = db.QReserve("q")
+	if m.Body != "a" || m.Deliveries != 2 {
+		t.Errorf("got %v, expect a delivered twice", m)
+	}
+	if ok, _ := db.QNack("q", m.ID); !ok {
+		t.Errorf("got %t, expect true", ok)
+	}
+	if ok, _ := db.QAck("q", m.ID); ok {
+		t.Errorf("dead message should not be acknowledged")
+	}
+	strs, _ := db.LRange("q-dead", 0, -1)
+	if strings.Join(strs, ",") != "a" {
+		t.Errorf("got %v, expect [a]", strs)
+	}
+
+	// the queue outlives its messages, keeping its options
+	v, ok := db.Get("q")
+	if !ok || v.Len() != 0 || v.(*Queue).Options().MaxDeliveries != 2 {
+		t.Errorf("empty queue should be kept")
+	}
+
+	// dead messages stay in flight if the dead letter list can't receive them
+	db.SetValue("q-dead", NewString("x"))
+	db.QPush("q", "c")
+	m, _, _ = db.QReserve("q")
+	db.QNack("q", m.ID)
+	m, _, _ = db.QReserve("q")
+	if _, err := db.QNack("q", m.ID); err == nil {
+		t.Errorf("got nil, expect wrong type error")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := db.RemoveExpired(); err == nil {
+		t.Errorf("got nil, expect wrong type error")
+	}
+	if _, _, err := db.QLen("q"); err == nil {
+		t.Errorf("got nil, expect wrong type error")
+	}
+	if v, _ := db.Get("q"); v.(*Queue).Inflight() != 1 {
+		t.Errorf("got %d, expect 1 in flight", v.(*Queue).Inflight())
+	}
+
+	// delivering and acknowledging messages gives their space back
+	db.QPush("q-size", "x")
+	m, _, _ = db.QReserve("q-size")
+	db.QAck("q-size", m.ID)
+	size := db.size
+	for i := 0; i < 1000; i++ {
+		db.QPush("q-size", "message")
+		m, _, _ = db.QReserve("q-size")
+		db.QAck("q-size", m.ID)
+	}
+	if db.size != size {
+		t.Errorf("got %d, expect %d", db.size, size)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	db := Default().Use("test")
+	now := time.Now()
+	db.Schedule("jobs", "later", now.Add(time.Hour))
+	db.Schedule("jobs", "b", now.Add(-time.Second))
+	db.Schedule("jobs", "a", now.Add(-time.Minute))
+	if ok, _ := db.Unschedule("jobs", "later"); !ok {
+		t.Errorf("got %t, expect true", ok)
+	}
+
+	payloads, _ := db.PollDue("jobs", now, 1)
+	if strings.Join(payloads, ",") != "a" {
+		t.Errorf("got %v, expect [a]", payloads)
+	}
+
+	db.Schedule("jobs", "c", now.Add(-time.Millisecond))
+	if _, err := db.NewScheduler("jobs", SchedulerOptions{}); err != ErrSchedulerTarget {
+		t.Errorf("got %v, expect %v", err, ErrSchedulerTarget)
+	}
+	if _, err := db.NewScheduler("jobs", SchedulerOptions{Target: "t", Handler: func(string) {}}); err != ErrSchedulerTarget {
+		t.Errorf("got %v, expect %v", err, ErrSchedulerTarget)
+	}
+	s, _ := db.NewScheduler("jobs", SchedulerOptions{Interval: time.Millisecond, Target: "jobs-ready"})
+	s.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, expect := range []string{"b", "c"} {
+		if _, p, err := db.BLPop(ctx, "jobs-ready"); p != expect {
+			t.Errorf("got %s %v, expect %s", p, err, expect)
+		}
+	}
+	s.Stop()
+	if _, ok := db.Get("jobs"); ok {
+		t.Errorf("empty schedule should be removed")
+	}
+
+	handled := make(chan string, 1)
+	db.Schedule("jobs", "d", now)
+	s, _ = db.NewScheduler("jobs", SchedulerOptions{Interval: time.Millisecond, Handler: func(p string) {
+		handled <- p
+	}})
+	s.Start()
+	if p := <-handled; p != "d" {
+		t.Errorf("got %s, expect d", p)
+	}
+	s.Stop()
+
+	// payloads that a panicking handler didn't handle are scheduled again
+	db.Schedule("jobs", "e", now)
+	db.Schedule("jobs", "f", now)
+	s, _ = db.NewScheduler("jobs", SchedulerOptions{Handler: func(p string) {
+		panic(p)
+	}})
+	if err := s.Poll(now); !errors.Is(err, ErrHandlerPanic) {
+		t.Errorf("got %v, expect %v", err, ErrHandlerPanic)
+	}
+	if due, _ := db.PollDue("jobs", now, 0); strings.Join(due, ",") != "e,f" {
+		t.Errorf("got %v, expect [e f]", due)
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	a := NewSet([]string{"1", "2", "3", "4"})
+	b := NewSet([]string{"3", "4", "5"})
+	c := NewSet([]string{"4", "5"})
+	sorted := func(set *Set) string {
+		strs := set.GetAll()
+		slices.Sort(strs)
+		return strings.Join(strs, ",")
+	}
+	if got := sorted(a.Intersect(b, c)); got != "4" {
+		t.Errorf("got %s, expect 4", got)
+	}
+	if got := sorted(a.Intersect(b, nil)); got != "" {
+		t.Errorf("got %s, expect empty", got)
+	}
+	if got := sorted(a.Diff(b, c)); got != "1,2" {
+		t.Errorf("got %s, expect 1,2", got)
+	}
+	if got := sorted(a.SymmetricDiff(b)); got != "1,2,5" {
+		t.Errorf("got %s, expect 1,2,5", got)
+	}
+	if !c.IsSubset(b) || b.IsSubset(c) {
+		t.Errorf("got %t %t, expect true false", c.IsSubset(b), b.IsSubset(c))
+	}
+
+	db := Default().Use("test")
+	db.SAdd("sa", "1", "2", "3", "4")
+	db.SAdd("sb", "3", "4", "5")
+	if n, _ := db.SUnionStore("sdst", "sa", "sb", "snone"); n != 5 {
+		t.Errorf("got %d, expect 5", n)
+	}
+	if n, _ := db.SDiffStore("sdst", "sa", "sb"); n != 2 {
+		t.Errorf("got %d, expect 2", n)
+	}
+	strs, _ := db.SInter("sa", "sb", "sdst")
+	if len(strs) != 0 {
+		t.Errorf("got %v, expect []", strs)
+	}
+	if n, _ := db.SInterStore("sdst", "sa", "snone"); n != 0 {
+		t.Errorf("got %d, expect 0", n)
+	}
+	if _, ok := db.Get("sdst"); ok {
+		t.Errorf("empty result should remove the destination")
+	}
+}
+
+func TestEncodings(t *testing.T) {
+	set := NewSet([]string{"3", "1", "2"})
+	if set.Encoding() != EncodingIntset || strings.Join(set.GetAll(), ",") != "1,2,3" {
+		t.Errorf("got %s %v, expect intset [1 2 3]", set.Encoding(), set.GetAll())
+	}
+	intsetSize := set.Size()
+	set.Add("01")
+	if set.Encoding() != EncodingListpack || !set.Contains("01") || !set.Contains("1") {
+		t.Errorf("got %s, expect listpack", set.Encoding())
+	}
+	if set.Size() <= intsetSize {
+		t.Errorf("got %d, expect more than %d", set.Size(), intsetSize)
+	}
+	set.Add(strings.Repeat("x", SetMaxListpackValue+1))
+	if set.Encoding() != EncodingHashtable || set.Len() != 5 {
+		t.Errorf("got %s %d, expect hashtable 5", set.Encoding(), set.Len())
+	}
+
+	h := NewHash()
+	for i := 0; i <= HashMaxListpackEntries; i++ {
+		if i == HashMaxListpackEntries && h.Encoding() != EncodingListpack {
+			t.Errorf("got %s, expect listpack", h.Encoding())
+		}
+		h.Put(strconv.Itoa(i), "v")
+	}
+	if v, _ := h.Get("0"); h.Encoding() != EncodingHashtable || v != "v" {
+		t.Errorf("got %s %s, expect hashtable v", h.Encoding(), v)
+	}
+
+	z := NewZset()
+	z.Add(2, "b")
+	z.Add(1, "a")
+	if z.Encoding() != EncodingListpack {
+		t.Errorf("got %s, expect listpack", z.Encoding())
+	}
+	z.Add(3, strings.Repeat("c", ZsetMaxListpackValue+1))
+	if z.Encoding() != EncodingSkiplist {
+		t.Errorf("got %s, expect skiplist", z.Encoding())
+	}
+	if rank, _ := z.Rank("b"); rank != 1 {
+		t.Errorf("got %d, expect 1", rank)
+	}
+
+	// the database accounts for the size of each encoding
+	db := Default().Use("test")
+	db.SAdd("enc", "1", "2")
+	v, _ := db.GetSet("enc")
+	before, oldSize := db.getSize(), v.Size()
+	db.SAdd("enc", "a")
+	if after := db.getSize(); after-before != v.Size()-oldSize {
+		t.Errorf("got %d, expect %d", after-before, v.Size()-oldSize)
+	}
+}
+
+func TestHashFieldExpire(t *testing.T) {
+	db := Default().Use("test")
+	db.HSet("hexp", "a", "1")
+	db.HSet("hexp", "b", "2")
+	db.HSet("hexp", "c", "3")
+
+	res, _ := db.HExpire("hexp", 20*time.Millisecond, "a", "b", "none")
+	if res[0] != HFieldUpdated || res[1] != HFieldUpdated || res[2] != HFieldNotFound {
+		t.Errorf("got %v, expect [1 1 -2]", res)
+	}
+	res, _ = db.HExpireAt("hexp", time.Now().Add(-time.Second), "c")
+	if res[0] != HFieldDeleted {
+		t.Errorf("got %v, expect [2]", res)
+	}
+	res, _ = db.HPersist("hexp", "b", "b")
+	if res[0] != HFieldUpdated || res[1] != HFieldNoExpire {
+		t.Errorf("got %v, expect [1 -1]", res)
+	}
+	ttls, _ := db.HTTL("hexp", "a", "b", "c")
+	if ttls[0] <= 0 || ttls[0] > 20*time.Millisecond || ttls[1] != HFieldNoExpire || ttls[2] != HFieldNotFound {
+		t.Errorf("got %v, expect [~20ms -1 -2]", ttls)
+	}
+
+	// HIncrBy keeps the expire time, HSet removes it
+	db.HSet("hexp", "n", "1")
+	db.HExpire("hexp", time.Hour, "n")
+	db.HIncrBy("hexp", "n", 1)
+	if ttls, _ := db.HTTL("hexp", "n"); ttls[0] <= 0 {
+		t.Errorf("got %v, expect a positive ttl", ttls[0])
+	}
+	db.HSet("hexp", "n", "5")
+	if ttls, _ := db.HTTL("hexp", "n"); ttls[0] != HFieldNoExpire {
+		t.Errorf("got %v, expect -1", ttls[0])
+	}
+
+	// expired fields are dropped lazily, including by FetchHash
+	time.Sleep(30 * time.Millisecond)
+	if h, _ := db.GetHash("hexp"); h.Contains("a") {
+		t.Errorf("expired field should be removed")
+	}
+	if ok, _ := db.HExists("hexp", "a"); ok {
+		t.Errorf("expired field should be removed")
+	}
+	if n, _ := db.HLen("hexp"); n != 2 {
+		t.Errorf("got %d, expect 2", n)
+	}
+
+	// and by the cleaner, deleting the empty hash
+	db.HExpire("hexp", time.Millisecond, "b", "n")
+	time.Sleep(5 * time.Millisecond)
+	db.RemoveExpired()
+	if _, ok := db.cache["hexp"]; ok {
+		t.Errorf("empty hash should be removed")
+	}
+}
+
+func TestHashFieldCommands(t *testing.T) {
+	db := Default().Use("test")
+	db.HMSet("hf", map[string]string{"n": "10", "f": "1.5", "s": "hello"})
+
+	if f, err := db.HIncrByFloat("hf", "f", 0.25); err != nil || f != 1.75 {
+		t.Errorf("got %v %v, expect 1.75", f, err)
+	}
+	if _, err := db.HIncrByFloat("hf", "s", 1); err != ErrNotFloat {
+		t.Errorf("got %v, expect %v", err, ErrNotFloat)
+	}
+	if _, err := db.HIncrByFloat("hf", "f", math.Inf(1)); err != ErrNaNOrInfinity {
+		t.Errorf("got %v, expect %v", err, ErrNaNOrInfinity)
+	}
+	db.HSet("hf", "max", strconv.FormatInt(math.MaxInt64, 10))
+	if _, err := db.HIncrBy("hf", "max", 1); err != ErrOverflow {
+		t.Errorf("got %v, expect %v", err, ErrOverflow)
+	}
+	if _, err := db.HIncrBy("hf-none", "n", math.MaxInt64); err != nil {
+		t.Error(err)
+	}
+	if _, err := db.HIncrByFloat("hf-new", "n", math.NaN()); err != ErrNaNOrInfinity {
+		t.Errorf("got %v, expect %v", err, ErrNaNOrInfinity)
+	}
+	if _, ok := db.Get("hf-new"); ok {
+		t.Errorf("failed increment should not create the hash")
+	}
+
+	keys, _ := db.HKeys("hf")
+	vals, _ := db.HVals("hf")
+	if len(keys) != 4 || len(vals) != 4 {
+		t.Errorf("got %v %v, expect 4 fields", keys, vals)
+	}
+	if n, _ := db.HStrLen("hf", "s"); n != 5 {
+		t.Errorf("got %d, expect 5", n)
+	}
+	if fields, _ := db.HRandField("hf", -10); len(fields) != 10 {
+		t.Errorf("got %d, expect 10", len(fields))
+	}
+	if fields, _ := db.HRandFieldWithValues("hf", 10); len(fields) != 4 {
+		t.Errorf("got %d, expect 4", len(fields))
+	}
+	if _, err := db.HRandField("hf", math.MinInt); err != ErrOutOfMemory {
+		t.Errorf("got %v, expect %v", err, ErrOutOfMemory)
+	}
+
+	// a full scan returns every matching field exactly once
+	for i := 0; i < 300; i++ {
+		db.HSet("hscan", "field:"+strconv.Itoa(i), "v")
+		db.HSet("hscan", "other:"+strconv.Itoa(i), "v")
+	}
+	seen := make(map[string]int)
+	var cursor uint64
+	for {
+		var fields []HashField
+		cursor, fields, _ = db.HScan("hscan", cursor, "field:[12]?", 50)
+		for _, f := range fields {
+			seen[f.Field]++
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	if len(seen) != 20 {
+		t.Errorf("got %d, expect 20", len(seen))
+	}
+	for field, n := range seen {
+		if n != 1 || !strings.HasPrefix(field, "field:") {
+			t.Errorf("got %s %d times, expect once", field, n)
+		}
+	}
+	cursor, _, _ = db.HScan("hscan", 0, "", 10)
+	if next, fields, _ := db.HScan("hscan", cursor, "", math.MaxInt); next != 0 || len(fields) != 590 {
+		t.Errorf("got %d %d, expect 0 590", next, len(fields))
+	}
+	// fields added during a scan are indexed
+	db.HSet("hscan", "new", "v")
+	if _, fields, _ := db.HScan("hscan", 0, "new", math.MaxInt); len(fields) != 1 {
+		t.Errorf("got %d, expect 1", len(fields))
+	}
+}
+
+func TestBytes(t *testing.T) {
+	db := Default().Use("test")
+	blob := []byte{0, 1, 0xff, 0xfe, '\n', 0}
+	db.SetBytes("blob", blob)
+	b, ok := db.GetBytes("blob")
+	if !ok || string(b) != string(blob) {
+		t.Errorf("got %v, expect %v", b, blob)
+	}
+	b[0] = 42
+	db.ViewBytes("blob", func(b []byte) {
+		if b[0] != 0 {
+			t.Errorf("GetBytes should return a copy")
+		}
+	})
+
+	db.SetValue("blob-str", NewString(string(blob)))
+	if b, _ := db.FetchBytes("blob-str"); string(b) != string(blob) {
+		t.Errorf("got %v, expect %v", b, blob)
+	}
+	if _, err := db.FetchBytes("blob-none"); err != ErrNotFound {
+		t.Errorf("got %v, expect %v", err, ErrNotFound)
+	}
+	db.RPush("blob-list", string(blob))
+	if err := db.ViewBytes("blob-list", func([]byte) {}); !errors.Is(err, ErrWrongType) {
+		t.Errorf("got %v, expect %v", err, ErrWrongType)
+	}
+
+	// containers are binary-safe
+	members := []string{string(blob), "\x00", "", "1\x00", "\xff\xfe"}
+	db.RPush("blob-list", members...)
+	db.SAdd("blob-set", members...)
+	for _, m := range members {
+		db.HSet("blob-hash", m, m)
+	}
+	strs, _ := db.LRange("blob-list", 1, -1)
+	if !slices.Equal(strs, members) {
+		t.Errorf("got %q, expect %q", strs, members)
+	}
+	for _, m := range members {
+		if ok, _ := db.SIsMember("blob-set", m); !ok {
+			t.Errorf("%q should be in the set", m)
+		}
+		if v, ok, _ := db.HGet("blob-hash", m); !ok || v != m {
+			t.Errorf("got %q, expect %q", v, m)
+		}
+	}
+	if n, _ := db.SCard("blob-set"); n != len(members) {
+		t.Errorf("got %d, expect %d", n, len(members))
+	}
+}
+
+func TestBitmap(t *testing.T) {
+	db := Default().Use("test")
+	for _, offset := range []uint64{1, 7, 100} {
+		if old, _ := db.SetBit("dau", offset, 1); old != 0 {
+			t.Errorf("got %d, expect 0", old)
+		}
+	}
+	if old, _ := db.SetBit("dau", 7, 0); old != 1 {
+		t.Errorf("got %d, expect 1", old)
+	}
+	if bit, _ := db.GetBit("dau", 100); bit != 1 {
+		t.Errorf("got %d, expect 1", bit)
+	}
+	if v, _ := db.Get("dau"); v.Size() != 13 {
+		t.Errorf("got %d, expect 13", v.Size())
+	}
+	if n, _ := db.BitCount("dau"); n != 2 {
+		t.Errorf("got %d, expect 2", n)
+	}
+	if n, _ := db.BitCountRange("dau", 2, 100, UnitBit); n != 1 {
+		t.Errorf("got %d, expect 1", n)
+	}
+	if n, _ := db.BitCountRange("dau", -1, -1, UnitByte); n != 1 {
+		t.Errorf("got %d, expect 1", n)
+	}
+
+	if pos, _ := db.BitPos("dau", 1); pos != 1 {
+		t.Errorf("got %d, expect 1", pos)
+	}
+	if pos, _ := db.BitPosRange("dau", 1, 1, -1, UnitByte); pos != 100 {
+		t.Errorf("got %d, expect 100", pos)
+	}
+	db.SetValue("ones", NewString("\xff\xff"))
+	if pos, _ := db.BitPos("ones", 0); pos != 16 {
+		t.Errorf("got %d, expect 16", pos)
+	}
+	if pos, _ := db.BitPosRange("ones", 0, 0, -1, UnitByte); pos != -1 {
+		t.Errorf("got %d, expect -1", pos)
+	}
+
+	db.SetValue("b1", NewString("\xf0\x0f"))
+	db.SetValue("b2", NewString("\xff"))
+	if n, _ := db.BitOp(BitAnd, "bdst", "b1", "b2"); n != 2 {
+		t.Errorf("got %d, expect 2", n)
+	}
+	if b, _ := db.GetBytes("bdst"); string(b) != "\xf0\x00" {
+		t.Errorf("got %q, expect \\xf0\\x00", b)
+	}
+	db.BitOp(BitXor, "bdst", "b1", "b2")
+	if b, _ := db.GetBytes("bdst"); string(b) != "\x0f\x0f" {
+		t.Errorf("got %q, expect \\x0f\\x0f", b)
+	}
+	db.BitOp(BitNot, "bdst", "b2")
+	if b, _ := db.GetBytes("bdst"); string(b) != "\x00" {
+		t.Errorf("got %q, expect \\x00", b)
+	}
+	if _, err := db.BitOp(BitNot, "bdst", "b1", "b2"); err != ErrBitOpNotArity {
+		t.Errorf("got %v, expect %v", err, ErrBitOpNotArity)
+	}
+	if _, err := db.BitOp(BitAnd, "bdst"); err != ErrWrongNumberOfArgs {
+		t.Errorf("got %v, expect %v", err, ErrWrongNumberOfArgs)
+	}
+
+	db.SetValue("bstr", NewString("a"))
+	db.SetBit("bstr", 6, 1)
+	db.SetBit("bstr", 15, 1)
+	if n, err := db.Append("bstr", "z"); err != nil || n != 3 {
+		t.Errorf("got %d %v, expect 3 nil", n, err)
+	}
+	if s, _ := db.GetString("bstr"); s.ToString() != "c\x01z" {
+		t.Errorf("got %q, expect c\\x01z", s.ToString())
+	}
+	if _, err := db.SetBit("bstr", 8*(1<<30), 1); err != ErrOutOfMemory {
+		t.Errorf("got %v, expect %v", err, ErrOutOfMemory)
+	}
+}
+
+func TestBitField(t *testing.T) {
+	db := Default().Use("test")
+	u8, _ := ParseBitFieldType("u8")
+	i5, _ := ParseBitFieldType("i5")
+	if _, err := ParseBitFieldType("u64"); err != ErrInvalidBitFieldType {
+		t.Errorf("got %v, expect %v", err, ErrInvalidBitFieldType)
+	}
+
+	res, _ := db.BitField("bf",
+		BitFieldOp{Op: BitFieldSet, Type: u8, Offset: 1, Scaled: true, Value: 200},
+		BitFieldOp{Op: BitFieldIncrBy, Type: u8, Offset: 8, Value: 100},
+		BitFieldOp{Op: BitFieldIncrBy, Type: u8, Offset: 8, Value: 250, Overflow: OverflowSat},
+		BitFieldOp{Op: BitFieldIncrBy, Type: u8, Offset: 8, Value: 1, Overflow: OverflowFail},
+		BitFieldOp{Op: BitFieldSet, Type: i5, Offset: 0, Value: 15},
+		BitFieldOp{Op: BitFieldIncrBy, Type: i5, Offset: 0, Value: 1},
+		BitFieldOp{Op: BitFieldIncrBy, Type: i5, Offset: 0, Value: -100, Overflow: OverflowSat},
+		BitFieldOp{Op: BitFieldGet, Type: u8, Offset: 8},
+	)
+	expect := []any{int64(0), int64(44), int64(255), nil, int64(0), int64(-16), int64(-16), int64(255)}
+	for i, r := range res {
+		var got any
+		if r != nil {
+			got = *r
+		}
+		if got != expect[i] {
+			t.Errorf("op %d: got %v, expect %v", i, got, expect[i])
+		}
+	}
+
+	for _, op := range []BitFieldOp{
+		{Op: BitFieldSet, Type: u8, Offset: math.MaxInt64 - 1, Scaled: true, Value: 1},
+		{Op: BitFieldSet, Type: u8, Offset: math.MaxInt64 - 3, Value: 1},
+	} {
+		if _, err := db.BitField("bf", op); err != ErrOffsetOutOfRange {
+			t.Errorf("got %v, expect %v", err, ErrOffsetOutOfRange)
+		}
+	}
+
+	if res, _ := db.BitField("bf-none", BitFieldOp{Op: BitFieldGet, Type: u8}); *res[0] != 0 {
+		t.Errorf("got %d, expect 0", *res[0])
+	}
+	if _, ok := db.Get("bf-none"); ok {
+		t.Errorf("gets should not create the bitmap")
+	}
+}
+
+func TestHyperLogLog(t *testing.T) {
+	db := Default().Use("test")
+	if created, _ := db.PFAdd("hll"); !created {
+		t.Errorf("got %v, expect %v", created, true)
+	}
+	if changed, _ := db.PFAdd("hll", "a", "b", "c"); !changed {
+		t.Errorf("got %v, expect %v", changed, true)
+	}
+	if changed, _ := db.PFAdd("hll", "a", "b"); changed {
+		t.Errorf("got %v, expect %v", changed, false)
+	}
+	if n, _ := db.PFCount("hll"); n != 3 {
+		t.Errorf("got %d, expect 3", n)
+	}
+
+	h := NewHyperLogLog()
+	for i := 0; i < 100000; i++ {
+		h.Add(strconv.Itoa(i))
+		if i == 100 && h.Encoding() != "sparse" {
+			t.Errorf("got %s, expect sparse", h.Encoding())
+		}
+	}
+	if h.Encoding() != "dense" {
+		t.Errorf("got %s, expect dense", h.Encoding())
+	}
+	if n := h.Count(); math.Abs(float64(n)-100000) > 2000 {
+		t.Errorf("got %d, expect about 100000", n)
+	}
+
+	for i := 0; i < 1000; i++ {
+		db.PFAdd("hll1", strconv.Itoa(i))
+		db.PFAdd("hll2", strconv.Itoa(i+500))
+	}
+	if n, _ := db.PFCount("hll1", "hll2", "hll-none"); math.Abs(float64(n)-1500) > 30 {
+		t.Errorf("got %d, expect about 1500", n)
+	}
+	if n, _ := db.PFCount("hll1"); math.Abs(float64(n)-1000) > 20 {
+		t.Errorf("got %d, expect about 1000", n)
+	}
+	if err := db.PFMerge("hll1", "hll2"); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	if n, _ := db.PFCount("hll1"); math.Abs(float64(n)-1500) > 30 {
+		t.Errorf("got %d, expect about 1500", n)
+	}
+
+	db.SetValue("hll-str", NewString("x"))
+	if _, err := db.PFAdd("hll-str", "a"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("got %v, expect %v", err, ErrWrongType)
+	}
+}
+
+func TestBloom(t *testing.T) {
+	db := Default().Use("test")
+	if err := db.BFReserve("bf1", 0.001, 1000); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	if err := db.BFReserve("bf1", 0.001, 1000); err != ErrKeyExists {
+		t.Errorf("got %v, expect %v", err, ErrKeyExists)
+	}
+	if err := db.BFReserve("bf2", 1, 1000); err != ErrInvalidErrorRate {
+		t.Errorf("got %v, expect %v", err, ErrInvalidErrorRate)
+	}
+	if err := db.BFReserve("bf2", 0.01, 0); err != ErrInvalidCapacity {
+		t.Errorf("got %v, expect %v", err, ErrInvalidCapacity)
+	}
+	if err := db.BFReserve("bf2", 0.01, 100_000_000); err != ErrOutOfMemory {
+		t.Errorf("got %v, expect %v", err, ErrOutOfMemory)
+	}
+
+	items := make([]string, 1000)
+	for i := range items {
+		items[i] = "item" + strconv.Itoa(i)
+	}
+	db.BFMAdd("bf1", items...)
+	if added, _ := db.BFAdd("bf1", "item0"); added {
+		t.Errorf("got %v, expect %v", added, false)
+	}
+	exists, _ := db.BFMExists("bf1", items...)
+	if slices.Contains(exists, false) {
+		t.Errorf("added items should exist")
+	}
+	fp := 0
+	for i := 0; i < 10000; i++ {
+		if ok, _ := db.BFExists("bf1", "other"+strconv.Itoa(i)); ok {
+			fp++
+		}
+	}
+	if fp > 30 {
+		t.Errorf("got %d false positives, expect about 10", fp)
+	}
+
+	if added, _ := db.BFAdd("bf3", "a"); !added {
+		t.Errorf("got %v, expect %v", added, true)
+	}
+	if v, _ := db.Get("bf3"); v.(*Bloom).Capacity() != BloomDefaultCapacity {
+		t.Errorf("got %d, expect %d", v.(*Bloom).Capacity(), BloomDefaultCapacity)
+	}
+	if ok, _ := db.BFExists("bf-none", "a"); ok {
+		t.Errorf("got %v, expect %v", ok, false)
+	}
+
+	v, _ := db.Get("bf1")
+	data, err := v.(*Bloom).MarshalBinary()
+	if err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	b := new(Bloom)
+	if err := b.UnmarshalBinary(data); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	if b.Len() != 1000 || b.ErrorRate() != 0.001 || !b.Exists("item999") || b.Exists("other0") != v.(*Bloom).Exists("other0") {
+		t.Errorf("unmarshaled filter differs from the original")
+	}
+	if err := b.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Errorf("got nil, expect an error")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	db := Default().Use("test-load")
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (Valuer, error) {
+		calls.Add(1)
+		<-release
+		if strings.HasPrefix(key, "missing") {
+			return nil, ErrNotFound
+		}
+		return NewString("loaded " + key), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := db.Load(context.Background(), "k1", loader)
+			if err != nil || v.(*String).ToString() != "loaded k1" {
+				t.Errorf("got %v, %v, expect loaded k1", v, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("got %d loader calls, expect 1", n)
+	}
+	if _, err := db.Load(context.Background(), "k1", loader); err != nil || calls.Load() != 1 {
+		t.Errorf("loaded values should be cached")
+	}
+	if _, err := db.Load(context.Background(), "missing1", loader); err != ErrNotFound {
+		t.Errorf("got %v, expect %v", err, ErrNotFound)
+	}
+
+	db.SetGuard(NewWhitelistGuard("k2"))
+	db.SetValue("k3", NewString("v3"))
+	calls.Store(0)
+	if _, err := db.Load(context.Background(), "missing2", loader); err != ErrNotFound {
+		t.Errorf("got %v, expect %v", err, ErrNotFound)
+	}
+	if _, err := db.Load(context.Background(), "k2", loader); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	db.Remove("k3")
+	if _, err := db.Load(context.Background(), "k3", loader); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("got %d loader calls, expect 2", n)
+	}
+
+	g := NewBloomGuard(1000, 0.01)
+	g.Add("k4")
+	if !g.MayExist("k4") || g.MayExist("missing3") {
+		t.Errorf("bloom guard should know k4 only")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	db.SetGuard(nil)
+	if _, err := db.Load(ctx, "k5", func(ctx context.Context, key string) (Valuer, error) {
+		time.Sleep(10 * time.Millisecond)
+		return NewString("v5"), nil
+	}); err != context.Canceled {
+		t.Errorf("got %v, expect %v", err, context.Canceled)
+	}
+}
+
+func TestLoadPanic(t *testing.T) {
+	db := Default().Use("test-load-panic")
+	release := make(chan struct{})
+	var calls atomic.Int32
+	loader := func(ctx context.Context, key string) (Valuer, error) {
+		if calls.Add(1) == 1 {
+			<-release
+			panic("backend down")
+		}
+		return NewString("loaded"), nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = db.Load(context.Background(), "k", loader)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for _, err := range errs {
+		if !errors.Is(err, ErrLoaderPanic) {
+			t.Errorf("got %v, expect %v", err, ErrLoaderPanic)
+		}
+	}
+
+	// the failed call isn't shared with later Loads
+	if v, err := db.Load(context.Background(), "k", loader); err != nil || v.(*String).ToString() != "loaded" {
+		t.Errorf("got %v %v, expect loaded", v, err)
+	}
+}
+
+func TestCountMinSketch(t *testing.T) {
+	db := Default().Use("test")
+	if err := db.CMSInitByProb("cms1", 0.001, 0.01); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	if err := db.CMSInitByDim("cms1", 10, 10); err != ErrKeyExists {
+		t.Errorf("got %v, expect %v", err, ErrKeyExists)
+	}
+	if err := db.CMSInitByDim("cms2", 0, 10); err != ErrInvalidDimensions {
+		t.Errorf("got %v, expect %v", err, ErrInvalidDimensions)
+	}
+	if err := db.CMSInitByDim("cms2", 1<<30, 1); err != ErrOutOfMemory {
+		t.Errorf("got %v, expect %v", err, ErrOutOfMemory)
+	}
+	if _, err := db.CMSIncrBy("cms-none", "a", 1); err != ErrNotFound {
+		t.Errorf("got %v, expect %v", err, ErrNotFound)
+	}
+
+	for i := 0; i < 1000; i++ {
+		db.CMSIncrBy("cms1", strconv.Itoa(i), uint64(i%10+1))
+	}
+	if n, _ := db.CMSIncrBy("cms1", "9", 5); n < 15 || n > 25 {
+		t.Errorf("got %d, expect about 15", n)
+	}
+	counts, _ := db.CMSQuery("cms1", "0", "19", "none")
+	if counts[0] < 1 || counts[0] > 12 || counts[1] < 10 || counts[1] > 21 || counts[2] > 11 {
+		t.Errorf("got %v, expect about [1 10 0]", counts)
+	}
+
+	db.CMSInitByProb("cms2", 0.001, 0.01)
+	db.CMSIncrBy("cms2", "0", 100)
+	if err := db.CMSMerge("cms2", []string{"cms1"}, []uint64{2}); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	if n, _ := db.CMSQuery("cms2", "0"); n[0] < 102 || n[0] > 125 {
+		t.Errorf("got %d, expect about 102", n[0])
+	}
+	db.CMSInitByDim("cms3", 10, 10)
+	if err := db.CMSMerge("cms3", []string{"cms1"}, nil); err != ErrIncompatibleSketch {
+		t.Errorf("got %v, expect %v", err, ErrIncompatibleSketch)
+	}
+
+	other := Default().Use("test-cms")
+	other.CMSInitByProb("cms", 0.001, 0.01)
+	if err := other.CMSMergeFrom(db, "cms", []string{"cms1", "cms2"}, nil); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	v, _ := other.Get("cms")
+	data, _ := v.(*CountMinSketch).MarshalBinary()
+	c := new(CountMinSketch)
+	if err := c.UnmarshalBinary(data); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	if c.Query("0") != v.(*CountMinSketch).Query("0") || c.Count() != 3*(5500+5)+100 {
+		t.Errorf("unmarshaled sketch differs from the original")
+	}
+}
+
+func TestTopK(t *testing.T) {
+	db := Default().Use("test")
+	if err := db.TopKReserve("topk1", 5, 0, 0, 0); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	if err := db.TopKReserve("topk2", 5, 8, 7, 1); err != ErrInvalidDecay {
+		t.Errorf("got %v, expect %v", err, ErrInvalidDecay)
+	}
+	if err := db.TopKReserve("topk2", 5, 1<<30, 1, 0); err != ErrOutOfMemory {
+		t.Errorf("got %v, expect %v", err, ErrOutOfMemory)
+	}
+
+	// item i occurs 100*(10-i) times for the first 10, once for the others
+	var items []string
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 100*(10-i); j++ {
+			items = append(items, "hot"+strconv.Itoa(i))
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		items = append(items, "cold"+strconv.Itoa(i))
+	}
+	rand.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+	if _, err := db.TopKAdd("topk1", items...); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+
+	list, _ := db.TopKList("topk1")
+	for i, e := range list {
+		if e.Item != "hot"+strconv.Itoa(i) {
+			t.Errorf("got %v, expect hot%d", list, i)
+			break
+		}
+	}
+	if res, _ := db.TopKQuery("topk1", "hot0", "hot9"); !res[0] || res[1] {
+		t.Errorf("got %v, expect [true false]", res)
+	}
+	if counts, _ := db.TopKCount("topk1", "hot0"); counts[0] < 900 || counts[0] > 1000 {
+		t.Errorf("got %d, expect about 1000", counts[0])
+	}
+
+	db.TopKReserve("topk2", 5, 0, 0, 0)
+	for i := 0; i < 2000; i++ {
+		db.TopKAdd("topk2", "hot9")
+	}
+	other := Default().Use("test-topk")
+	other.TopKReserve("topk", 5, 0, 0, 0)
+	if err := other.TopKMergeFrom(db, "topk", "topk1", "topk-none"); err != ErrNotFound {
+		t.Errorf("got %v, expect %v", err, ErrNotFound)
+	}
+	if err := other.TopKMergeFrom(db, "topk", "topk1", "topk2"); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	if list, _ := other.TopKList("topk"); len(list) != 5 || list[0].Item != "hot9" || list[1].Item != "hot0" {
+		t.Errorf("got %v, expect hot9 and hot0 first", list)
+	}
+
+	v, _ := other.Get("topk")
+	data, _ := v.(*TopK).MarshalBinary()
+	tk := new(TopK)
+	if err := tk.UnmarshalBinary(data); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	if !slices.Equal(tk.List(), v.(*TopK).List()) || tk.Count("hot1") != v.(*TopK).Count("hot1") {
+		t.Errorf("unmarshaled top-k differs from the original")
+	}
+
+	// a large increment takes over a colliding bucket without drawing each occurrence
+	tk, _ = NewTopK(2, 1, 1, 0)
+	tk.IncrBy("a", 100)
+	tk.IncrBy("b", math.MaxUint32)
+	if count := tk.Count("b"); count < math.MaxUint32/2 {
+		t.Errorf("got %d, expect about %d", count, uint32(math.MaxUint32))
+	}
+}
+
+func TestTDigest(t *testing.T) {
+	db := Default().Use("test")
+	if err := db.TDigestCreate("td1", 0); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	if err := db.TDigestCreate("td1", 0); err != ErrKeyExists {
+		t.Errorf("got %v, expect %v", err, ErrKeyExists)
+	}
+	if err := db.TDigestCreate("td2", -1); err != ErrInvalidCompression {
+		t.Errorf("got %v, expect %v", err, ErrInvalidCompression)
+	}
+	if q, _ := db.TDigestQuantile("td1", 0.5); !math.IsNaN(q[0]) {
+		t.Errorf("got %f, expect NaN", q[0])
+	}
+
+	// latencies uniformly spread over [1, 100000]
+	values := make([]float64, 100000)
+	for i := range values {
+		values[i] = float64(i + 1)
+	}
+	rand.Shuffle(len(values), func(i, j int) { values[i], values[j] = values[j], values[i] })
+	for i := 0; i < len(values); i += 1000 {
+		if err := db.TDigestAdd("td1", values[i:i+1000]...); err != nil {
+			t.Errorf("got %v, expect nil", err)
+		}
+	}
+	if err := db.TDigestAdd("td1", math.NaN()); err != ErrNotFinite {
+		t.Errorf("got %v, expect %v", err, ErrNotFinite)
+	}
+
+	qs, _ := db.TDigestQuantile("td1", 0, 0.5, 0.99, 0.999, 1)
+	for i, expect := range []float64{1, 50000, 99000, 99900, 100000} {
+		if math.Abs(qs[i]-expect) > expect*0.01 {
+			t.Errorf("quantile %d: got %f, expect about %f", i, qs[i], expect)
+		}
+	}
+	if _, err := db.TDigestQuantile("td1", 1.5); err != ErrInvalidQuantile {
+		t.Errorf("got %v, expect %v", err, ErrInvalidQuantile)
+	}
+	cdf, _ := db.TDigestCDF("td1", 0, 25000, 99000, 100000)
+	for i, expect := range []float64{0, 0.25, 0.99, 1} {
+		if math.Abs(cdf[i]-expect) > 0.005 {
+			t.Errorf("cdf %d: got %f, expect about %f", i, cdf[i], expect)
+		}
+	}
+	if min, _ := db.TDigestMin("td1"); min != 1 {
+		t.Errorf("got %f, expect 1", min)
+	}
+	if max, _ := db.TDigestMax("td1"); max != 100000 {
+		t.Errorf("got %f, expect 100000", max)
+	}
+	if v, _ := db.Get("td1"); v.Size() > 16*1000 {
+		t.Errorf("got size %d, expect at most 16000", v.Size())
+	}
+
+	db.TDigestCreate("td2", 200)
+	db.TDigestAdd("td2", 100001, 200000)
+	other := Default().Use("test-tdigest")
+	if err := other.TDigestMergeFrom(db, "td", "td1", "td2"); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	v, _ := other.Get("td")
+	td := v.(*TDigest)
+	if td.Compression() != 200 || td.Count() != 100002 || td.Max() != 200000 {
+		t.Errorf("got compression %f, count %f, max %f, expect 200, 100002, 200000", td.Compression(), td.Count(), td.Max())
+	}
+
+	data, _ := td.MarshalBinary()
+	clone := new(TDigest)
+	if err := clone.UnmarshalBinary(data); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	q1, _ := clone.Quantile(0.9)
+	q2, _ := td.Quantile(0.9)
+	if q1 != q2 || clone.Count() != td.Count() {
+		t.Errorf("unmarshaled digest differs from the original")
+	}
+
+	db.TDigestReset("td1")
+	if n, _ := db.TDigestMax("td1"); !math.IsNaN(n) {
+		t.Errorf("got %f, expect NaN", n)
+	}
+}
@@ -0,0 +1,192 @@
+package mycache
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// QueueOptions configures a Queue
+type QueueOptions struct {
+	// VisibilityTimeout is how long a reserved message stays invisible to other consumers
+	// before it returns to the queue, unless it is acknowledged
+	VisibilityTimeout time.Duration
+	// MaxDeliveries is the number of deliveries after which a message that isn't acknowledged
+	// goes to the dead letter list instead of returning to the queue. 0 means no limit.
+	MaxDeliveries int
+	// DeadLetterKey is the key of the List receiving the bodies of dead messages.
+	// Dead messages are dropped if it's empty.
+	DeadLetterKey string
+}
+
+// DefaultQueueOptions are the options of the queues created by QPush
+var DefaultQueueOptions = QueueOptions{VisibilityTimeout: 30 * time.Second}
+
+// Message is a message delivered by a Queue
+type Message struct {
+	ID   string
+	Body string
+	// Deliveries is the number of times the message was reserved, including this one
+	Deliveries int
+}
+
+// Queue is a reliable queue of messages. Consumers reserve messages, which become invisible
+// until they are acknowledged, or until their visibility timeout elapses and they return to the queue.
+type Queue struct {
+	opts QueueOptions
+	// ready holds the ids of the visible messages, in delivery order
+	ready    *List
+	messages map[string]*Message
+	// inflight holds the deadlines of the reserved messages
+	inflight map[string]time.Time
+	nextID   uint64
+	size     uint64
+}
+
+// NewQueue creates an empty queue with opts
+func NewQueue(opts QueueOptions) *Queue {
+	return &Queue{
+		opts:     opts,
+		ready:    NewEmptyList(),
+		messages: make(map[string]*Message),
+		inflight: make(map[string]time.Time),
+	}
+}
+
+func (q *Queue) Size() uint64 {
+	return q.size + q.ready.Size()
+}
+
+// Len returns the number of messages, either ready or reserved
+func (q *Queue) Len() int {
+	return len(q.messages)
+}
+
+func (q *Queue) Type() string {
+	return "Queue"
+}
+
+// Options returns the options of the queue
+func (q *Queue) Options() QueueOptions {
+	return q.opts
+}
+
+// Ready returns the number of messages waiting to be reserved
+func (q *Queue) Ready() int {
+	return q.ready.Len()
+}
+
+// Inflight returns the number of reserved messages that aren't acknowledged yet
+func (q *Queue) Inflight() int {
+	return len(q.inflight)
+}
+
+// Push appends a message with body to the queue and returns its id
+func (q *Queue) Push(body string) string {
+	q.nextID++
+	id := strconv.FormatUint(q.nextID, 10)
+	q.messages[id] = &Message{ID: id, Body: body}
+	q.ready.Add(id)
+	q.size += uint64(len(id) + len(body))
+	return id
+}
+
+// Reserve delivers the first ready message, which stays invisible until now plus the visibility timeout.
+// Call Requeue first so that timed out messages are delivered again.
+func (q *Queue) Reserve(now time.Time) (Message, bool) {
+	id, ok := q.ready.PopFront()
+	if !ok {
+		return Message{}, false
+	}
+	m := q.messages[id]
+	m.Deliveries++
+	q.inflight[id] = now.Add(q.opts.VisibilityTimeout)
+	return *m, true
+}
+
+// Ack deletes the reserved message with id. It returns false if the message isn't reserved,
+// e.g. because it timed out.
+func (q *Queue) Ack(id string) bool {
+	if _, ok := q.inflight[id]; !ok {
+		return false
+	}
+	delete(q.inflight, id)
+	q.delete(id)
+	return true
+}
+
+// Nack returns the reserved message with id to the head of the queue, or removes it if it
+// reached the delivery limit, in which case it's returned as dead.
+// ok is false if the message isn't reserved.
+func (q *Queue) Nack(id string) (dead *Message, ok bool) {
+	if _, ok := q.inflight[id]; !ok {
+		return nil, false
+	}
+	delete(q.inflight, id)
+	return q.release(id), true
+}
+
+// Requeue returns the reserved messages whose visibility timeout elapsed at now to the head
+// of the queue, and removes and returns those that reached the delivery limit.
+func (q *Queue) Requeue(now time.Time) []Message {
+	var dead []Message
+	for _, id := range q.TimedOut(now) {
+		delete(q.inflight, id)
+		if m := q.release(id); m != nil {
+			dead = append(dead, *m)
+		}
+	}
+	return dead
+}
+
+// TimedOut returns the ids of the reserved messages whose visibility timeout elapsed at now,
+// newest first
+func (q *Queue) TimedOut(now time.Time) []string {
+	var expired []string
+	for id, deadline := range q.inflight {
+		if !now.Before(deadline) {
+			expired = append(expired, id)
+		}
+	}
+	// release the newest first, so that the oldest ends up at the head
+	slices.SortFunc(expired, func(a, b string) int {
+		if len(a) != len(b) {
+			return cmp.Compare(len(b), len(a))
+		}
+		return cmp.Compare(b, a)
+	})
+	return expired
+}
+
+// Dying returns the reserved messages among ids that reached the delivery limit,
+// i.e. those that Nack or Requeue would remove as dead
+func (q *Queue) Dying(ids ...string) []Message {
+	var dead []Message
+	for _, id := range ids {
+		if _, ok := q.inflight[id]; ok && q.exhausted(q.messages[id]) {
+			dead = append(dead, *q.messages[id])
+		}
+	}
+	return dead
+}
+
+func (q *Queue) exhausted(m *Message) bool {
+	return q.opts.MaxDeliveries > 0 && m.Deliveries >= q.opts.MaxDeliveries
+}
+
+// release makes the message with id visible again, or deletes and returns it if it reached the delivery limit
+func (q *Queue) release(id string) *Message {
+	m := q.messages[id]
+	if q.exhausted(m) {
+		q.delete(id)
+		return m
+	}
+	q.ready.PushFront(id)
+	return nil
+}
+
+func (q *Queue) delete(id string) {
+	q.size -= uint64(len(id) + len(q.messages[id].Body))
+	delete(q.messages, id)
+}
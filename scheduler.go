@@ -0,0 +1,187 @@
+package mycache
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Schedule adds payload to the schedule stored at key, a sorted set scored by due time in
+// unix milliseconds. Scheduling a payload again changes its due time.
+// It returns true if payload is new.
+func (db *database) Schedule(key string, payload string, due time.Time) (bool, error) {
+	n, err := db.ZAdd(key, ZAddOptions{}, ZMember{Score: float64(due.UnixMilli()), Member: payload})
+	return n == 1, err
+}
+
+// Unschedule removes payload from the schedule stored at key, and returns whether it was scheduled
+func (db *database) Unschedule(key string, payload string) (bool, error) {
+	n, err := db.ZRem(key, payload)
+	return n == 1, err
+}
+
+// PollDue atomically removes and returns at most count payloads due at now from the schedule
+// stored at key, earliest first. A count <= 0 means no limit.
+func (db *database) PollDue(key string, now time.Time, count int) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.pollDue(key, now, count)
+}
+
+// MoveDue is like PollDue, but atomically appends the due payloads to the list stored at dst,
+// serving the goroutines blocked on it. It returns the number of moved payloads.
+func (db *database) MoveDue(key string, dst string, now time.Time, count int) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// check dst first, so that nothing is lost if it holds another type
+	if _, err := db.getList(dst); err != nil {
+		return 0, err
+	}
+	payloads, err := db.pollDue(key, now, count)
+	if err != nil || len(payloads) == 0 {
+		return 0, err
+	}
+	if _, err := db.pushList(dst, false, payloads); err != nil {
+		return 0, err
+	}
+	db.signal(dst)
+	return len(payloads), nil
+}
+
+// pollDue removes the payloads due at now without locking
+func (db *database) pollDue(key string, now time.Time, count int) ([]string, error) {
+	zset, err := db.getZset(key)
+	if err != nil || zset == nil {
+		return nil, err
+	}
+	if count <= 0 {
+		count = -1
+	}
+
+	due := zset.RangeByScore(ScoreBound{Score: math.Inf(-1)}, ScoreBound{Score: float64(now.UnixMilli())}, 0, count)
+	oldSize := zset.Size()
+	zset.removeRange(0, len(due))
+	db.updateContainer(key, zset, oldSize)
+
+	payloads := make([]string, len(due))
+	for i, m := range due {
+		payloads[i] = m.Member
+	}
+	return payloads, nil
+}
+
+// SchedulerOptions configures a Scheduler. Exactly one of Target and Handler must be set.
+type SchedulerOptions struct {
+	// Interval is the time between two polls of the schedule
+	Interval time.Duration
+	// Target is the key of the List receiving the due payloads
+	Target string
+	// Handler is called with each due payload, outside of the database lock.
+	// If it panics, the payload and the rest of the batch are scheduled again to be due at once.
+	Handler func(payload string)
+	// BatchSize is the maximum number of payloads handled per poll, 0 for no limit
+	BatchSize int
+}
+
+// DefaultSchedulerInterval is the poll interval of a Scheduler without one
+const DefaultSchedulerInterval = 100 * time.Millisecond
+
+// Scheduler polls a schedule in the background, and moves the due payloads to a list
+// or passes them to a handler.
+type Scheduler struct {
+	db   *database
+	key  string
+	opts SchedulerOptions
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewScheduler returns a scheduler for the schedule stored at key. Call Start to run it.
+// It returns ErrSchedulerTarget unless exactly one of opts.Target and opts.Handler is set.
+func (db *database) NewScheduler(key string, opts SchedulerOptions) (*Scheduler, error) {
+	if (opts.Target == "") == (opts.Handler == nil) {
+		return nil, ErrSchedulerTarget
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultSchedulerInterval
+	}
+	return &Scheduler{db: db, key: key, opts: opts}, nil
+}
+
+// Start runs the scheduler in a new goroutine. It does nothing if the scheduler is running.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.stop, s.done)
+}
+
+// Stop stops the scheduler and waits for the current poll to finish.
+// The scheduler can be started again afterwards.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop, s.done = nil, nil
+}
+
+func (s *Scheduler) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			_ = s.Poll(now)
+		}
+	}
+}
+
+// Poll handles the payloads due at now once.
+// If the handler panics, the payloads it didn't handle are scheduled again at now,
+// and an error wrapping ErrHandlerPanic is returned.
+func (s *Scheduler) Poll(now time.Time) error {
+	if s.opts.Handler == nil {
+		_, err := s.db.MoveDue(s.key, s.opts.Target, now, s.opts.BatchSize)
+		return err
+	}
+
+	payloads, err := s.db.PollDue(s.key, now, s.opts.BatchSize)
+	for i, p := range payloads {
+		if err := s.handle(p); err != nil {
+			for _, p := range payloads[i:] {
+				s.db.Schedule(s.key, p, now)
+			}
+			return err
+		}
+	}
+	return err
+}
+
+// handle calls the handler with payload, turning a panic into an error wrapping ErrHandlerPanic
+func (s *Scheduler) handle(payload string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrHandlerPanic, r)
+		}
+	}()
+	s.opts.Handler(payload)
+	return nil
+}
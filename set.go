@@ -1,15 +1,31 @@
 package mycache
 
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+)
+
+// Set is a set of unique strings. Small sets are stored compactly: sets of integers as a
+// sorted array, and other small sets as an unordered array, see SetMaxIntsetEntries.
 type Set struct {
-	s map[string]struct{}
+	enc string
+	// ints holds the members of an intset, sorted
+	ints []int64
+	// small holds the members of a listpack
+	small []string
+	s     map[string]struct{}
+	// bytes is the total length of the members, except for an intset
+	bytes uint64
 }
 
 func NewEmptySet() *Set {
-	return &Set{s: make(map[string]struct{})}
+	return &Set{enc: EncodingIntset}
 }
 
 func NewSet(strs []string) *Set {
-	set := &Set{s: make(map[string]struct{})}
+	set := NewEmptySet()
 	for _, str := range strs {
 		set.Add(str)
 	}
@@ -17,61 +33,307 @@ func NewSet(strs []string) *Set {
 }
 
 func (set *Set) Size() uint64 {
-	var size uint64 = 0
-	for k := range set.s {
-		size += uint64(len(k))
+	switch set.enc {
+	case EncodingIntset:
+		return uint64(sliceHeaderSize + 8*len(set.ints))
+	case EncodingListpack:
+		return uint64(sliceHeaderSize+stringHeaderSize*len(set.small)) + set.bytes
+	default:
+		return uint64((stringHeaderSize+mapEntryOverhead)*len(set.s)) + set.bytes
 	}
-	return size
+}
+
+// maxSizeWith returns an upper bound of the size of the set once n members of bytes total length
+// are added, whatever encoding it converts to
+func (set *Set) maxSizeWith(n int, bytes uint64) uint64 {
+	size := set.bytes
+	if set.enc == EncodingIntset {
+		size = uint64(maxInt64Len * len(set.ints))
+	}
+	return size + bytes + uint64(sliceHeaderSize+(stringHeaderSize+mapEntryOverhead)*(set.Len()+n))
 }
 
 func (set *Set) Len() int {
-	return len(set.s)
+	switch set.enc {
+	case EncodingIntset:
+		return len(set.ints)
+	case EncodingListpack:
+		return len(set.small)
+	default:
+		return len(set.s)
+	}
 }
 
 func (set *Set) Type() string {
 	return "Set"
 }
 
+// Encoding returns how the set is stored: EncodingIntset, EncodingListpack or EncodingHashtable
+func (set *Set) Encoding() string {
+	return set.enc
+}
+
 func (set *Set) Add(s string) {
-	set.s[s] = struct{}{}
+	switch set.enc {
+	case EncodingIntset:
+		if n, ok := parseIntsetMember(s); ok {
+			i, found := slices.BinarySearch(set.ints, n)
+			if found {
+				return
+			}
+			if len(set.ints) < SetMaxIntsetEntries {
+				set.ints = slices.Insert(set.ints, i, n)
+				return
+			}
+		}
+		set.convert(s)
+		set.Add(s)
+		return
+	case EncodingListpack:
+		if slices.Contains(set.small, s) {
+			return
+		}
+		if len(set.small) < SetMaxListpackEntries && len(s) <= SetMaxListpackValue {
+			set.small = append(set.small, s)
+			set.bytes += uint64(len(s))
+			return
+		}
+		set.convert(s)
+		set.Add(s)
+		return
+	}
+
+	if _, ok := set.s[s]; !ok {
+		set.s[s] = struct{}{}
+		set.bytes += uint64(len(s))
+	}
+}
+
+// convert moves the members to the smallest encoding that can also hold s
+func (set *Set) convert(s string) {
+	strs := set.GetAll()
+	enc := EncodingListpack
+	if len(strs) >= SetMaxListpackEntries || len(s) > SetMaxListpackValue {
+		enc = EncodingHashtable
+	}
+	for _, str := range strs {
+		if len(str) > SetMaxListpackValue {
+			enc = EncodingHashtable
+		}
+	}
+
+	*set = Set{enc: enc}
+	if enc == EncodingListpack {
+		set.small = make([]string, 0, len(strs)+1)
+	} else {
+		set.s = make(map[string]struct{}, len(strs)+1)
+	}
+	for _, str := range strs {
+		set.Add(str)
+	}
 }
 
 func (set *Set) Contains(s string) bool {
-	_, ok := set.s[s]
-	return ok
+	switch set.enc {
+	case EncodingIntset:
+		n, ok := parseIntsetMember(s)
+		if !ok {
+			return false
+		}
+		_, found := slices.BinarySearch(set.ints, n)
+		return found
+	case EncodingListpack:
+		return slices.Contains(set.small, s)
+	default:
+		_, ok := set.s[s]
+		return ok
+	}
 }
 
 func (set *Set) Remove(s string) {
-	delete(set.s, s)
+	switch set.enc {
+	case EncodingIntset:
+		if n, ok := parseIntsetMember(s); ok {
+			if i, found := slices.BinarySearch(set.ints, n); found {
+				set.ints = slices.Delete(set.ints, i, i+1)
+			}
+		}
+	case EncodingListpack:
+		if i := slices.Index(set.small, s); i >= 0 {
+			set.small = slices.Delete(set.small, i, i+1)
+			set.bytes -= uint64(len(s))
+		}
+	default:
+		if _, ok := set.s[s]; ok {
+			delete(set.s, s)
+			set.bytes -= uint64(len(s))
+		}
+	}
 }
 
+// GetAll returns the members, in ascending order for an intset and in insertion order for a listpack
 func (set *Set) GetAll() []string {
-	strs := make([]string, 0)
-	for k := range set.s {
-		strs = append(strs, k)
-	}
+	strs := make([]string, 0, set.Len())
+	set.each(func(s string) {
+		strs = append(strs, s)
+	})
 	return strs
 }
 
-func (set *Set) Intersect(s Set) *Set {
-	res := &Set{s: make(map[string]struct{})}
-	for k1 := range set.s {
-		for k2 := range s.s {
-			if k1 == k2 {
-				res.s[k1] = struct{}{}
+// each calls fn for each member
+func (set *Set) each(fn func(s string)) {
+	switch set.enc {
+	case EncodingIntset:
+		for _, n := range set.ints {
+			fn(strconv.FormatInt(n, 10))
+		}
+	case EncodingListpack:
+		for _, s := range set.small {
+			fn(s)
+		}
+	default:
+		for s := range set.s {
+			fn(s)
+		}
+	}
+}
+
+// Intersect returns the members of set that are in all the other sets.
+// It iterates over the smallest set, and nil sets are treated as empty.
+func (set *Set) Intersect(others ...*Set) *Set {
+	smallest := set
+	for _, o := range others {
+		if o == nil {
+			return NewEmptySet()
+		}
+		if o.Len() < smallest.Len() {
+			smallest = o
+		}
+	}
+
+	res := NewEmptySet()
+	all := append([]*Set{set}, others...)
+	smallest.each(func(k string) {
+		for _, o := range all {
+			if o != smallest && !o.Contains(k) {
+				return
 			}
 		}
+		res.Add(k)
+	})
+	return res
+}
+
+// Union returns the members of set and of all the other sets. nil sets are treated as empty.
+func (set *Set) Union(others ...*Set) *Set {
+	res := NewEmptySet()
+	set.each(res.Add)
+	for _, o := range others {
+		if o != nil {
+			o.each(res.Add)
+		}
+	}
+	return res
+}
+
+// Diff returns the members of set that are in none of the other sets. nil sets are treated as empty.
+func (set *Set) Diff(others ...*Set) *Set {
+	res := NewEmptySet()
+	set.each(func(k string) {
+		for _, o := range others {
+			if o != nil && o.Contains(k) {
+				return
+			}
+		}
+		res.Add(k)
+	})
+	return res
+}
+
+// SymmetricDiff returns the members that are in exactly one of set and other
+func (set *Set) SymmetricDiff(other *Set) *Set {
+	res := set.Diff(other)
+	if other == nil {
+		return res
 	}
+	other.each(func(k string) {
+		if !set.Contains(k) {
+			res.Add(k)
+		}
+	})
 	return res
 }
 
-func (set *Set) Union(s Set) *Set {
-	res := &Set{s: make(map[string]struct{})}
-	for k1 := range set.s {
-		res.s[k1] = struct{}{}
+// IsSubset returns whether all the members of set are in other
+func (set *Set) IsSubset(other *Set) bool {
+	if set.Len() == 0 {
+		return true
+	}
+	if other == nil || set.Len() > other.Len() {
+		return false
+	}
+	subset := true
+	set.each(func(k string) {
+		if subset && !other.Contains(k) {
+			subset = false
+		}
+	})
+	return subset
+}
+
+// Pop removes and returns an arbitrary member
+func (set *Set) Pop() (string, bool) {
+	if set.Len() == 0 {
+		return "", false
+	}
+
+	var s string
+	switch set.enc {
+	case EncodingIntset:
+		s = strconv.FormatInt(set.ints[rand.Intn(len(set.ints))], 10)
+	case EncodingListpack:
+		s = set.small[rand.Intn(len(set.small))]
+	default:
+		for k := range set.s {
+			s = k
+			break
+		}
+	}
+	set.Remove(s)
+	return s, true
+}
+
+// Random returns count distinct random members if count is positive, or -count
+// random members that may repeat if count is negative.
+func (set *Set) Random(count int) []string {
+	return randomSample(set.GetAll(), count)
+}
+
+// maxSamplePrealloc bounds the capacity preallocated for a sample with repetitions,
+// whose length is chosen by the caller
+const maxSamplePrealloc = 1024
+
+// randomSample returns count distinct random elements of all if count is positive, or -count
+// random elements that may repeat if count is negative. It reorders all.
+func randomSample[T any](all []T, count int) []T {
+	if count >= 0 {
+		rand.Shuffle(len(all), func(i, j int) { all[i], all[j] = all[j], all[i] })
+		if count < len(all) {
+			all = all[:count]
+		}
+		return all
+	}
+
+	n := math.MaxInt
+	if count != math.MinInt {
+		n = -count
+	}
+	if len(all) == 0 {
+		n = 0
 	}
-	for k2 := range s.s {
-		res.s[k2] = struct{}{}
+	res := make([]T, 0, min(n, maxSamplePrealloc))
+	for len(res) < n {
+		res = append(res, all[rand.Intn(len(all))])
 	}
 	return res
 }
@@ -0,0 +1,75 @@
+package skiplist
+
+// Iterator walks a SkipList in both directions. It is either positioned on a node or invalid,
+// and a new Iterator is invalid until one of the Seek methods is called.
+// Removing the current node from the list invalidates the position.
+type Iterator[K, V any] struct {
+	list *SkipList[K, V]
+	node *Node[K, V]
+}
+
+// Iterator returns a new iterator over the list
+func (list *SkipList[K, V]) Iterator() *Iterator[K, V] {
+	return &Iterator[K, V]{list: list}
+}
+
+// Valid returns whether the iterator is positioned on a node
+func (it *Iterator[K, V]) Valid() bool {
+	return it.node != nil
+}
+
+// Node returns the current node, or nil if the iterator is invalid
+func (it *Iterator[K, V]) Node() *Node[K, V] {
+	return it.node
+}
+
+// Key returns the key of the current node
+func (it *Iterator[K, V]) Key() K {
+	return it.node.key
+}
+
+// Value returns the value of the current node
+func (it *Iterator[K, V]) Value() V {
+	return it.node.value
+}
+
+// Next moves to the next node and returns whether the iterator is still valid
+func (it *Iterator[K, V]) Next() bool {
+	if it.node != nil {
+		it.node = it.node.Next()
+	}
+	return it.node != nil
+}
+
+// Prev moves to the previous node and returns whether the iterator is still valid
+func (it *Iterator[K, V]) Prev() bool {
+	if it.node != nil {
+		it.node = it.node.Prev()
+	}
+	return it.node != nil
+}
+
+// SeekToFirst moves to the first node and returns whether the list is not empty
+func (it *Iterator[K, V]) SeekToFirst() bool {
+	it.node = it.list.Front()
+	return it.node != nil
+}
+
+// SeekToLast moves to the last node and returns whether the list is not empty
+func (it *Iterator[K, V]) SeekToLast() bool {
+	it.node = it.list.Back()
+	return it.node != nil
+}
+
+// Seek moves to the first node with a key greater than or equal to key,
+// and returns whether there is one
+func (it *Iterator[K, V]) Seek(key K) bool {
+	return it.SeekFunc(func(n *Node[K, V]) bool { return it.list.compare(n.key, key) < 0 })
+}
+
+// SeekFunc moves to the first node for which before returns false, and returns whether there is one.
+// See SkipList.Search.
+func (it *Iterator[K, V]) SeekFunc(before func(*Node[K, V]) bool) bool {
+	it.node, _ = it.list.Search(before)
+	return it.node != nil
+}
@@ -1,25 +1,34 @@
 package skiplist
 
 // Node is a K-V node, saving elementNode as well
-type Node struct {
-	next []*Node
-	// key is a float64 type for comparing
-	key   float64
-	value string
+type Node[K, V any] struct {
+	next []*Node[K, V]
+	// span[i] is the number of nodes skipped by next[i], counting next[i] itself
+	span []int
+	// prev is the previous node on the bottom level, nil for the first node
+	prev *Node[K, V]
+	// key is compared with the comparator of the list
+	key   K
+	value V
 }
 
 // Key allows retrieval of the key for a given Element
-func (e *Node) Key() float64 {
+func (e *Node[K, V]) Key() K {
 	return e.key
 }
 
 // Value allows retrieval of the value for a given Element
-func (e *Node) Value() string {
+func (e *Node[K, V]) Value() V {
 	return e.value
 }
 
 // Next returns the following Element or nil if we're at the end of the list.
 // Only operates on the bottom level of the skip list (a fully linked list).
-func (element *Node) Next() *Node {
+func (element *Node[K, V]) Next() *Node[K, V] {
 	return element.next[0]
 }
+
+// Prev returns the previous Element or nil if we're at the beginning of the list.
+func (element *Node[K, V]) Prev() *Node[K, V] {
+	return element.prev
+}
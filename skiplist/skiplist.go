@@ -1,6 +1,7 @@
 package skiplist
 
 import (
+	"cmp"
 	"math"
 	"math/rand"
 	"time"
@@ -11,27 +12,32 @@ const (
 	DefaultProbability float64 = 1 / math.E
 )
 
-type SkipList struct {
-	head        *Node
-	next        []*Node
+// SkipList is an ordered map from keys of type K to values of type V,
+// ordered by a comparator function.
+type SkipList[K, V any] struct {
+	head        *Node[K, V]
+	tail        *Node[K, V]
+	compare     func(a, b K) int
 	maxLevel    int
 	length      int
+	levels      int
 	randSource  rand.Source
 	probability float64
 	probTable   []float64
 }
 
-// NewWithMaxLevel creates a new skip list with MaxLevel set to the provided number.
+// NewFuncWithMaxLevel creates a new skip list ordered by compare, with MaxLevel set to the provided number.
+// compare must return a negative number if a < b, a positive number if a > b and 0 if they are equal.
 // maxLevel has to be int(math.Ceil(math.Log(N))) for DefaultProbability (where N is an upper bound on the
 // number of elements in a skip list). Returns a pointer to the new list.
-func NewWithMaxLevel(maxLevel int) *SkipList {
+func NewFuncWithMaxLevel[K, V any](compare func(a, b K) int, maxLevel int) *SkipList[K, V] {
 	if maxLevel < 1 || maxLevel > 64 {
 		panic("maxLevel for a SkipList must be a positive integer <= 64")
 	}
 
-	return &SkipList{
-		head:        &Node{next: make([]*Node, maxLevel)},
-		next:        make([]*Node, maxLevel),
+	return &SkipList[K, V]{
+		head:        &Node[K, V]{next: make([]*Node[K, V], maxLevel), span: make([]int, maxLevel)},
+		compare:     compare,
 		maxLevel:    maxLevel,
 		randSource:  rand.New(rand.NewSource(time.Now().UnixNano())),
 		probability: DefaultProbability,
@@ -39,74 +45,75 @@ func NewWithMaxLevel(maxLevel int) *SkipList {
 	}
 }
 
-// New creates a new skip list with default parameters. Returns a pointer to the new list.
-func New() *SkipList {
-	return NewWithMaxLevel(DefaultMaxLevel)
+// NewFunc creates a new skip list ordered by compare, with default parameters.
+func NewFunc[K, V any](compare func(a, b K) int) *SkipList[K, V] {
+	return NewFuncWithMaxLevel[K, V](compare, DefaultMaxLevel)
 }
 
-func (list *SkipList) Len() int {
+// NewWithMaxLevel creates a new skip list ordered by the natural order of the keys,
+// with MaxLevel set to the provided number.
+func NewWithMaxLevel[K cmp.Ordered, V any](maxLevel int) *SkipList[K, V] {
+	return NewFuncWithMaxLevel[K, V](cmp.Compare[K], maxLevel)
+}
+
+// New creates a new skip list ordered by the natural order of the keys, with default parameters.
+// Returns a pointer to the new list.
+func New[K cmp.Ordered, V any]() *SkipList[K, V] {
+	return NewWithMaxLevel[K, V](DefaultMaxLevel)
+}
+
+func (list *SkipList[K, V]) Len() int {
 	return list.length
 }
 
-func (list *SkipList) Size() uint64 {
-	var size uint64 = uint64(32 + 8*(len(list.probTable)+len(list.next)))
-	node := list.Front()
-	for node != nil {
-		size += uint64(len(node.Value()) + len(node.next)*8 + 8)
-		node = node.Next()
-	}
-	return size
+// Size returns the memory used by the structure of the list,
+// excluding the memory referenced by keys and values.
+func (list *SkipList[K, V]) Size() uint64 {
+	return uint64(48 + 8*len(list.probTable) + 16*(list.maxLevel+list.levels) + 8*list.length)
 }
 
 // Front returns the first node of the list
-func (list *SkipList) Front() *Node {
-	return list.next[0]
+func (list *SkipList[K, V]) Front() *Node[K, V] {
+	return list.head.next[0]
+}
+
+// Back returns the last node of the list
+func (list *SkipList[K, V]) Back() *Node[K, V] {
+	return list.tail
 }
 
 // Set inserts a value in the list with the specified key, ordered by the key
 // If the key exists, it updates the value in the existing node
 // Returns a pointer to the new element
-func (list *SkipList) Set(key float64, value string) *Node {
-	var node *Node
-	prevs := list.getPrevElementNodes(key)
+func (list *SkipList[K, V]) Set(key K, value V) *Node[K, V] {
+	var node *Node[K, V]
+	prevs, ranks := list.getPrevElementNodes(key)
 
 	// if key exists, only update the value
-	if node = prevs[0].next[0]; node != nil && node.key == key {
+	if node = prevs[0].next[0]; node != nil && list.compare(node.key, key) == 0 {
 		node.value = value
 		return node
 	}
 
 	// if key doesn't exist, create a new node
-	node = &Node{
-		next:  make([]*Node, list.randLevel()),
-		key:   key,
-		value: value,
-	}
-
-	for i := range node.next {
-		node.next[i] = prevs[i].next[i]
-		prevs[i].next[i] = node
-	}
-
-	list.length++
-	return node
+	return list.link(prevs, ranks, key, value)
 }
 
 // Get finds an element by key. It returns element pointer if found, nil if not found.
-func (list *SkipList) Get(key float64) *Node {
-	var node *Node = list.head
-	var next *Node
+func (list *SkipList[K, V]) Get(key K) *Node[K, V] {
+	var node *Node[K, V] = list.head
+	var next *Node[K, V]
 
 	// retrieve from list.maxLevel - 1 to 0, to achieve O(logN) time complexity
 	for i := list.maxLevel - 1; i >= 0; i-- {
 		next = node.next[i]
-		for next != nil && next.key < key {
+		for next != nil && list.compare(next.key, key) < 0 {
 			node = next
 			next = next.next[i]
 		}
 	}
 
-	if next != nil && next.key == key {
+	if next != nil && list.compare(next.key, key) == 0 {
 		return next
 	}
 	return nil
@@ -114,47 +121,210 @@ func (list *SkipList) Get(key float64) *Node {
 
 // Remove deletes an element with given key from the list.
 // Returns removed element pointer if found, nil if not found.
-func (list *SkipList) Remove(key float64) *Node {
-	prevs := list.getPrevElementNodes(key)
+func (list *SkipList[K, V]) Remove(key K) *Node[K, V] {
+	prevs, _ := list.getPrevElementNodes(key)
 
 	// found the node and remove it
-	if node := prevs[0].next[0]; node != nil && node.key == key {
-		for i, v := range node.next {
-			prevs[i].next[i] = v
-		}
-		list.length--
+	if node := prevs[0].next[0]; node != nil && list.compare(node.key, key) == 0 {
+		list.unlink(prevs, node)
 		return node
 	}
 	return nil
 }
 
 // Contains return if a key exists in the list, based on Get method
-func (list *SkipList) Contains(key float64) bool {
+func (list *SkipList[K, V]) Contains(key K) bool {
 	return list.Get(key) != nil
 }
 
-// getPrevElementNodes is the private search method that other functions use.
-// Finds the previous nodes on each level relative to the current Element and caches them in prevNodesCache.
-// Note that key doesn't have to exist.
-func (list *SkipList) getPrevElementNodes(key float64) []*Node {
-	var node *Node = list.head
-	var next *Node
-	prevNodesCache := make([]*Node, list.maxLevel)
+// Search returns the first node for which before returns false, and its rank, starting from 0.
+// before must hold for a prefix of the list, e.g. comparing keys against a bound.
+// If before holds for every node, Search returns nil and the length of the list.
+func (list *SkipList[K, V]) Search(before func(*Node[K, V]) bool) (*Node[K, V], int) {
+	prevs, ranks := list.search(before)
+	return prevs[0].next[0], ranks[0]
+}
+
+// Rank returns the rank, starting from 0, of the node with the specified key. Returns -1 if not found.
+func (list *SkipList[K, V]) Rank(key K) int {
+	prevs, ranks := list.getPrevElementNodes(key)
+	if node := prevs[0].next[0]; node != nil && list.compare(node.key, key) == 0 {
+		return ranks[0]
+	}
+	return -1
+}
+
+// GetByRank returns the node at rank, starting from 0, or nil if rank is out of range.
+func (list *SkipList[K, V]) GetByRank(rank int) *Node[K, V] {
+	if rank < 0 || rank >= list.length {
+		return nil
+	}
+
+	// count nodes from 1 so that the head is at 0
+	node := list.head
+	traversed := 0
+	for i := list.maxLevel - 1; i >= 0; i-- {
+		for node.next[i] != nil && traversed+node.span[i] <= rank+1 {
+			traversed += node.span[i]
+			node = node.next[i]
+		}
+		if traversed == rank+1 {
+			return node
+		}
+	}
+	return nil
+}
+
+// search is the private search method that other functions use.
+// Finds the previous nodes on each level relative to the first node for which before returns false,
+// along with the number of nodes up to and including each of them.
+func (list *SkipList[K, V]) search(before func(*Node[K, V]) bool) ([]*Node[K, V], []int) {
+	var node *Node[K, V] = list.head
+	var next *Node[K, V]
+	prevNodesCache := make([]*Node[K, V], list.maxLevel)
+	ranks := make([]int, list.maxLevel)
+	rank := 0
 
 	for i := list.maxLevel - 1; i >= 0; i-- {
 		next = node.next[i]
-		for next != nil && key > next.key {
+		for next != nil && before(next) {
+			rank += node.span[i]
 			node = next
 			next = next.next[i]
 		}
 		prevNodesCache[i] = node
+		ranks[i] = rank
+	}
+	return prevNodesCache, ranks
+}
+
+// getPrevElementNodes finds the previous nodes on each level relative to key and their ranks.
+// Note that key doesn't have to exist.
+func (list *SkipList[K, V]) getPrevElementNodes(key K) ([]*Node[K, V], []int) {
+	return list.search(func(n *Node[K, V]) bool {
+		return list.compare(n.key, key) < 0
+	})
+}
+
+// link inserts a new node after prevs, ranks being the number of nodes up to and including each of them.
+func (list *SkipList[K, V]) link(prevs []*Node[K, V], ranks []int, key K, value V) *Node[K, V] {
+	level := list.randLevel()
+	node := &Node[K, V]{
+		next:  make([]*Node[K, V], level),
+		span:  make([]int, level),
+		key:   key,
+		value: value,
+	}
+
+	for i := 0; i < level; i++ {
+		node.next[i] = prevs[i].next[i]
+		prevs[i].next[i] = node
+		node.span[i] = prevs[i].span[i] - (ranks[0] - ranks[i])
+		prevs[i].span[i] = ranks[0] - ranks[i] + 1
+	}
+	for i := level; i < list.maxLevel; i++ {
+		prevs[i].span[i]++
+	}
+
+	if prevs[0] != list.head {
+		node.prev = prevs[0]
+	}
+	if node.next[0] != nil {
+		node.next[0].prev = node
+	} else {
+		list.tail = node
+	}
+
+	list.length++
+	list.levels += level
+	return node
+}
+
+// unlink removes node, whose previous nodes on each level are prevs.
+func (list *SkipList[K, V]) unlink(prevs []*Node[K, V], node *Node[K, V]) {
+	for i := 0; i < list.maxLevel; i++ {
+		if i < len(node.next) && prevs[i].next[i] == node {
+			prevs[i].span[i] += node.span[i] - 1
+			prevs[i].next[i] = node.next[i]
+		} else {
+			prevs[i].span[i]--
+		}
+	}
+
+	if node.next[0] != nil {
+		node.next[0].prev = node.prev
+	} else {
+		list.tail = node.prev
+	}
+	list.length--
+	list.levels -= len(node.next)
+}
+
+// RemoveRangeFunc removes the consecutive nodes starting at the first node for which before
+// returns false, and ending before the first following node for which within returns false.
+// before must hold for a prefix of the list. Returns the removed nodes.
+func (list *SkipList[K, V]) RemoveRangeFunc(before, within func(*Node[K, V]) bool) []*Node[K, V] {
+	prevs, _ := list.search(before)
+	return list.removeFrom(prevs, within)
+}
+
+// RemoveRangeByKey removes the nodes with a key between min and max, both inclusive.
+// Returns the removed nodes.
+func (list *SkipList[K, V]) RemoveRangeByKey(min, max K) []*Node[K, V] {
+	return list.RemoveRangeFunc(
+		func(n *Node[K, V]) bool { return list.compare(n.key, min) < 0 },
+		func(n *Node[K, V]) bool { return list.compare(n.key, max) <= 0 },
+	)
+}
+
+// RemoveRangeByRank removes the nodes with a rank between start and stop, start included
+// and stop excluded. Returns the removed nodes.
+func (list *SkipList[K, V]) RemoveRangeByRank(start, stop int) []*Node[K, V] {
+	if start < 0 {
+		start = 0
+	}
+	if stop > list.length {
+		stop = list.length
+	}
+	if start >= stop {
+		return nil
+	}
+
+	prevs := make([]*Node[K, V], list.maxLevel)
+	node := list.head
+	traversed := 0
+	for i := list.maxLevel - 1; i >= 0; i-- {
+		for node.next[i] != nil && traversed+node.span[i] <= start {
+			traversed += node.span[i]
+			node = node.next[i]
+		}
+		prevs[i] = node
+	}
+
+	n := stop - start
+	return list.removeFrom(prevs, func(*Node[K, V]) bool {
+		n--
+		return n >= 0
+	})
+}
+
+// removeFrom removes the nodes following prevs while within returns true.
+// prevs stay valid predecessors after each removal, so it runs in O(k) for k removed nodes.
+func (list *SkipList[K, V]) removeFrom(prevs []*Node[K, V], within func(*Node[K, V]) bool) []*Node[K, V] {
+	var removed []*Node[K, V]
+	node := prevs[0].next[0]
+	for node != nil && within(node) {
+		next := node.next[0]
+		list.unlink(prevs, node)
+		removed = append(removed, node)
+		node = next
 	}
-	return prevNodesCache
+	return removed
 }
 
 // SetProbability changes the current P value of the list.
 // It doesn't alter any existing data, only changes how future insert heights are calculated.
-func (list *SkipList) SetProbability(newProbability float64) {
+func (list *SkipList[K, V]) SetProbability(newProbability float64) {
 	list.probability = newProbability
 	list.probTable = probabilityTable(list.probability, list.maxLevel)
 }
@@ -171,7 +341,7 @@ func probabilityTable(probability float64, MaxLevel int) (table []float64) {
 }
 
 // randLevel calculate the random level of a newly inserted node
-func (list *SkipList) randLevel() (level int) {
+func (list *SkipList[K, V]) randLevel() (level int) {
 	// Our random number source only has Int63(), so we have to produce a float64 from it
 	r := float64(list.randSource.Int63()) / (1 << 63)
 
@@ -1,11 +1,13 @@
 package skiplist
 
 import (
+	"cmp"
+	"strings"
 	"testing"
 )
 
 func TestNewLen(t *testing.T) {
-	list := New()
+	list := New[float64, string]()
 	len := list.Len()
 	if len != 0 {
 		t.Errorf("got %d, expect 0", len)
@@ -13,7 +15,7 @@ func TestNewLen(t *testing.T) {
 }
 
 func TestSet(t *testing.T) {
-	list := New()
+	list := New[float64, string]()
 	list.Set(1.0, "lbw")
 	len := list.Len()
 	if len != 1 {
@@ -22,7 +24,7 @@ func TestSet(t *testing.T) {
 }
 
 func TestGet(t *testing.T) {
-	list := New()
+	list := New[float64, string]()
 	list.Set(1.0, "lbw")
 	v := list.Get(1.0).Value()
 	if v != "lbw" {
@@ -31,7 +33,7 @@ func TestGet(t *testing.T) {
 }
 
 func TestRemoveContains(t *testing.T) {
-	list := New()
+	list := New[float64, string]()
 	list.Set(1.0, "lbw")
 	exist := list.Contains(1.0)
 	if !exist {
@@ -44,3 +46,113 @@ func TestRemoveContains(t *testing.T) {
 		t.Errorf("got %t, expect false", exist)
 	}
 }
+
+func TestComparator(t *testing.T) {
+	type pair struct {
+		score  float64
+		member string
+	}
+	list := NewFunc[pair, struct{}](func(a, b pair) int {
+		if a.score != b.score {
+			return cmp.Compare(a.score, b.score)
+		}
+		return strings.Compare(a.member, b.member)
+	})
+	list.Set(pair{1.0, "b"}, struct{}{})
+	list.Set(pair{1.0, "a"}, struct{}{})
+	list.Set(pair{0.5, "c"}, struct{}{})
+	list.Set(pair{1.0, "a"}, struct{}{})
+	if list.Len() != 3 {
+		t.Errorf("got %d, expect 3", list.Len())
+	}
+
+	members := ""
+	for node := list.Front(); node != nil; node = node.Next() {
+		members += node.Key().member
+	}
+	if members != "cab" {
+		t.Errorf("got %s, expect cab", members)
+	}
+
+	list.Remove(pair{1.0, "a"})
+	if r := list.Rank(pair{1.0, "b"}); r != 1 {
+		t.Errorf("got %d, expect 1", r)
+	}
+}
+
+func TestRank(t *testing.T) {
+	list := New[float64, string]()
+	keys := []float64{5, 3, 8, 1, 9, 7, 2, 6, 4, 0}
+	for _, k := range keys {
+		list.Set(k, "")
+	}
+	list.Remove(4)
+	list.Remove(7)
+
+	expect := []float64{0, 1, 2, 3, 5, 6, 8, 9}
+	for rank, k := range expect {
+		if r := list.Rank(k); r != rank {
+			t.Errorf("rank of %v is %d, expect %d", k, r, rank)
+		}
+		if node := list.GetByRank(rank); node == nil || node.Key() != k {
+			t.Errorf("node at rank %d is %v, expect %v", rank, node, k)
+		}
+	}
+	if list.GetByRank(len(expect)) != nil {
+		t.Errorf("got a node past the end")
+	}
+
+	node, rank := list.Search(func(n *Node[float64, string]) bool { return n.Key() < 4 })
+	if node.Key() != 5 || rank != 4 {
+		t.Errorf("got %v %d, expect 5 4", node.Key(), rank)
+	}
+}
+
+func TestIterator(t *testing.T) {
+	list := New[float64, string]()
+	for _, k := range []float64{3, 1, 4, 1.5, 9, 2.6} {
+		list.Set(k, "")
+	}
+
+	it := list.Iterator()
+	if !it.Seek(2) || it.Key() != 2.6 {
+		t.Errorf("seek(2) got %v, expect 2.6", it.Node())
+	}
+	it.Prev()
+	if it.Key() != 1.5 {
+		t.Errorf("got %v, expect 1.5", it.Key())
+	}
+
+	keys := []float64{}
+	for ok := it.SeekToLast(); ok; ok = it.Prev() {
+		keys = append(keys, it.Key())
+	}
+	if len(keys) != 6 || keys[0] != 9 || keys[5] != 1 {
+		t.Errorf("got %v, expect [9 4 3 2.6 1.5 1]", keys)
+	}
+	if it.Seek(10) {
+		t.Errorf("seek(10) got %v, expect invalid", it.Node())
+	}
+}
+
+func TestRemoveRange(t *testing.T) {
+	list := New[float64, string]()
+	for i := 0; i < 10; i++ {
+		list.Set(float64(i), "")
+	}
+
+	if removed := list.RemoveRangeByKey(2, 4); len(removed) != 3 || removed[0].Key() != 2 {
+		t.Errorf("got %d nodes, expect 3", len(removed))
+	}
+	if removed := list.RemoveRangeByRank(1, 3); len(removed) != 2 || removed[0].Key() != 1 || removed[1].Key() != 5 {
+		t.Errorf("got %d nodes, expect [1 5]", len(removed))
+	}
+	if list.Len() != 5 || list.Back().Key() != 9 || list.Back().Prev().Key() != 8 {
+		t.Errorf("got %d nodes, expect 5", list.Len())
+	}
+	for rank, k := range []float64{0, 6, 7, 8, 9} {
+		if node := list.GetByRank(rank); node.Key() != k {
+			t.Errorf("node at rank %d is %v, expect %v", rank, node.Key(), k)
+		}
+	}
+}
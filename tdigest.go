@@ -0,0 +1,359 @@
+package mycache
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+)
+
+// DefaultTDigestCompression is the compression of the t-digests created with a compression of 0
+const DefaultTDigestCompression = 100
+
+// TDigest summarizes a stream of samples as at most about compression clusters of close values,
+// called centroids, to estimate quantiles, especially extreme ones, in little space.
+// Samples are buffered and merged into the centroids in batches.
+type TDigest struct {
+	compression float64
+	// centroids are sorted by mean
+	centroids []tdCentroid
+	// buffer holds the samples not merged yet
+	buffer   []float64
+	min, max float64
+	// weight is the total weight of the centroids
+	weight float64
+}
+
+type tdCentroid struct {
+	mean   float64
+	weight float64
+}
+
+// NewTDigest returns an empty TDigest. A larger compression gives more accurate estimates
+// in more space, and 0 selects DefaultTDigestCompression.
+// It returns ErrInvalidCompression if compression is negative.
+func NewTDigest(compression float64) (*TDigest, error) {
+	if compression == 0 {
+		compression = DefaultTDigestCompression
+	}
+	if !(compression > 0 && compression <= math.MaxInt32) {
+		return nil, ErrInvalidCompression
+	}
+	t := &TDigest{compression: compression}
+	t.Reset()
+	return t, nil
+}
+
+func (t *TDigest) Size() uint64 {
+	return uint64(16*len(t.centroids) + 8*len(t.buffer))
+}
+
+// Len returns the number of samples, capped at the largest int
+func (t *TDigest) Len() int {
+	return int(min(t.Count(), math.MaxInt))
+}
+
+func (t *TDigest) Type() string {
+	return "TDigest"
+}
+
+// Compression returns the compression the digest was created with
+func (t *TDigest) Compression() float64 {
+	return t.compression
+}
+
+// Count returns the number of samples
+func (t *TDigest) Count() float64 {
+	return t.weight + float64(len(t.buffer))
+}
+
+// Reset removes all the samples
+func (t *TDigest) Reset() {
+	t.centroids, t.buffer = nil, nil
+	t.min, t.max = math.NaN(), math.NaN()
+	t.weight = 0
+}
+
+// Add adds samples. It returns ErrNotFinite, adding none of them, if a sample is NaN or infinite.
+func (t *TDigest) Add(values ...float64) error {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return ErrNotFinite
+		}
+	}
+	for _, v := range values {
+		if t.Count() == 0 {
+			t.min, t.max = v, v
+		}
+		t.min, t.max = math.Min(t.min, v), math.Max(t.max, v)
+		t.buffer = append(t.buffer, v)
+		if len(t.buffer) >= t.bufferSize() {
+			t.process()
+		}
+	}
+	return nil
+}
+
+// bufferSize is the number of samples buffered before they are merged into the centroids
+func (t *TDigest) bufferSize() int {
+	return int(5 * math.Ceil(t.compression))
+}
+
+// Min returns the smallest sample, or NaN if there is none
+func (t *TDigest) Min() float64 {
+	return t.min
+}
+
+// Max returns the largest sample, or NaN if there is none
+func (t *TDigest) Max() float64 {
+	return t.max
+}
+
+// Merge adds the samples summarized by others
+func (t *TDigest) Merge(others ...*TDigest) {
+	for _, o := range others {
+		if o.Count() == 0 {
+			continue
+		}
+		if t.Count() == 0 {
+			t.min, t.max = o.min, o.max
+		}
+		t.min, t.max = math.Min(t.min, o.min), math.Max(t.max, o.max)
+		t.centroids = append(t.centroids, o.centroids...)
+		t.weight += o.weight
+		t.buffer = append(t.buffer, o.buffer...)
+	}
+	t.process()
+}
+
+// process merges the buffered samples into the centroids, and merges adjacent centroids as long
+// as their weight stays within the limit of the k1 scale function, which keeps the centroids
+// at the extremes small
+func (t *TDigest) process() {
+	all := t.centroids
+	for _, v := range t.buffer {
+		all = append(all, tdCentroid{v, 1})
+	}
+	t.buffer = t.buffer[:0]
+	if len(all) == 0 {
+		return
+	}
+	slices.SortStableFunc(all, compareCentroids)
+
+	total := t.weight + float64(len(all)-len(t.centroids))
+	merged := make([]tdCentroid, 0, len(all))
+	cur, weightSoFar := all[0], 0.0
+	limit := t.quantileLimit(0)
+	for _, c := range all[1:] {
+		if (weightSoFar+cur.weight+c.weight)/total <= limit {
+			cur.weight += c.weight
+			cur.mean += (c.mean - cur.mean) * c.weight / cur.weight
+			continue
+		}
+		merged = append(merged, cur)
+		weightSoFar += cur.weight
+		limit = t.quantileLimit(weightSoFar / total)
+		cur = c
+	}
+	t.centroids = append(merged, cur)
+	t.weight = total
+}
+
+// quantileLimit returns the largest quantile a centroid starting at quantile q may reach,
+// which is one unit further on the scale k(q) = compression / 2π * asin(2q - 1)
+func (t *TDigest) quantileLimit(q float64) float64 {
+	k := t.compression/(2*math.Pi)*math.Asin(2*q-1) + 1
+	if k >= t.compression/4 {
+		return 1
+	}
+	return (math.Sin(k*2*math.Pi/t.compression) + 1) / 2
+}
+
+func compareCentroids(a, b tdCentroid) int {
+	switch {
+	case a.mean < b.mean:
+		return -1
+	case a.mean > b.mean:
+		return 1
+	}
+	return 0
+}
+
+// Quantile returns an estimate of the value below which a fraction q of the samples fall,
+// or NaN if there are no samples. It returns ErrInvalidQuantile if q isn't between 0 and 1.
+func (t *TDigest) Quantile(q float64) (float64, error) {
+	if !(q >= 0 && q <= 1) {
+		return 0, ErrInvalidQuantile
+	}
+	t.process()
+	c, total := t.centroids, t.weight
+	n := len(c)
+	switch {
+	case n == 0:
+		return math.NaN(), nil
+	case n == 1:
+		return c[0].mean, nil
+	}
+
+	// the samples are spread evenly around each centroid, over its weight, and a
+	// centroid of weight 1 is a sample. The extremes are at min and max.
+	index := q * total
+	if index < 1 {
+		return t.min, nil
+	}
+	if c[0].weight > 1 && index < c[0].weight/2 {
+		return t.min + (index-1)/(c[0].weight/2-1)*(c[0].mean-t.min), nil
+	}
+	if index > total-1 {
+		return t.max, nil
+	}
+	if c[n-1].weight > 1 && total-index <= c[n-1].weight/2 {
+		return t.max - (total-index-1)/(c[n-1].weight/2-1)*(t.max-c[n-1].mean), nil
+	}
+
+	weightSoFar := c[0].weight / 2
+	for i := 0; i < n-1; i++ {
+		dw := (c[i].weight + c[i+1].weight) / 2
+		if weightSoFar+dw <= index {
+			weightSoFar += dw
+			continue
+		}
+		leftUnit, rightUnit := 0.0, 0.0
+		if c[i].weight == 1 {
+			if index-weightSoFar < 0.5 {
+				return c[i].mean, nil
+			}
+			leftUnit = 0.5
+		}
+		if c[i+1].weight == 1 {
+			if weightSoFar+dw-index <= 0.5 {
+				return c[i+1].mean, nil
+			}
+			rightUnit = 0.5
+		}
+		z1 := index - weightSoFar - leftUnit
+		z2 := weightSoFar + dw - index - rightUnit
+		return weightedAverage(c[i].mean, z2, c[i+1].mean, z1), nil
+	}
+	return c[n-1].mean, nil
+}
+
+// weightedAverage returns the average of x1 and x2 with weights w1 and w2, kept between them
+func weightedAverage(x1, w1, x2, w2 float64) float64 {
+	if x1 > x2 {
+		x1, w1, x2, w2 = x2, w2, x1, w1
+	}
+	x := (x1*w1 + x2*w2) / (w1 + w2)
+	return math.Max(x1, math.Min(x, x2))
+}
+
+// CDF returns an estimate of the fraction of the samples at most x, or NaN if there are no samples
+func (t *TDigest) CDF(x float64) float64 {
+	t.process()
+	if len(t.centroids) == 0 {
+		return math.NaN()
+	}
+	if x < t.min {
+		return 0
+	}
+	if x >= t.max {
+		return 1
+	}
+
+	// interpolate between the centers of the centroids, min being at 0 and max at the total weight
+	prevX, prevW, weightSoFar := t.min, 0.0, 0.0
+	for _, c := range t.centroids {
+		mid := weightSoFar + c.weight/2
+		if x < c.mean {
+			return (prevW + (x-prevX)/(c.mean-prevX)*(mid-prevW)) / t.weight
+		}
+		prevX, prevW = c.mean, mid
+		weightSoFar += c.weight
+	}
+	return (prevW + (x-prevX)/(t.max-prevX)*(t.weight-prevW)) / t.weight
+}
+
+// Clone returns a copy of t
+func (t *TDigest) Clone() *TDigest {
+	clone := *t
+	clone.centroids = slices.Clone(t.centroids)
+	clone.buffer = slices.Clone(t.buffer)
+	return &clone
+}
+
+// MarshalBinary encodes the digest as its compression, min and max in 8 bytes each, followed by
+// the number of centroids as a uvarint and their means and weights in 8 bytes each, and
+// the number of buffered samples as a uvarint and the samples in 8 bytes each
+func (t *TDigest) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, 24+2*binary.MaxVarintLen64+t.Size())
+	for _, f := range [...]float64{t.compression, t.min, t.max} {
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(f))
+	}
+	data = binary.AppendUvarint(data, uint64(len(t.centroids)))
+	for _, c := range t.centroids {
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(c.mean))
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(c.weight))
+	}
+	data = binary.AppendUvarint(data, uint64(len(t.buffer)))
+	for _, v := range t.buffer {
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes data produced by MarshalBinary, replacing the content of the digest.
+// It returns ErrInvalidEncoding if data is malformed.
+func (t *TDigest) UnmarshalBinary(data []byte) error {
+	floats := func(n uint64) ([]float64, bool) {
+		if n > uint64(len(data)/8) {
+			return nil, false
+		}
+		fs := make([]float64, n)
+		for i := range fs {
+			fs[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+		}
+		data = data[8*n:]
+		return fs, true
+	}
+	count := func() (uint64, bool) {
+		n, size := binary.Uvarint(data)
+		if size <= 0 {
+			return 0, false
+		}
+		data = data[size:]
+		return n, true
+	}
+
+	header, ok := floats(3)
+	if !ok || !(header[0] > 0 && header[0] <= math.MaxInt32) {
+		return ErrInvalidEncoding
+	}
+	res := &TDigest{compression: header[0], min: header[1], max: header[2]}
+	n, ok := count()
+	if !ok || n > math.MaxInt32 {
+		return ErrInvalidEncoding
+	}
+	centroids, ok := floats(2 * n)
+	if !ok {
+		return ErrInvalidEncoding
+	}
+	for i := 0; i < len(centroids); i += 2 {
+		c := tdCentroid{centroids[i], centroids[i+1]}
+		if i > 0 && c.mean < res.centroids[len(res.centroids)-1].mean || !(c.weight > 0) {
+			return ErrInvalidEncoding
+		}
+		res.centroids = append(res.centroids, c)
+		res.weight += c.weight
+	}
+	n, ok = count()
+	if !ok {
+		return ErrInvalidEncoding
+	}
+	if res.buffer, ok = floats(n); !ok || len(data) != 0 {
+		return ErrInvalidEncoding
+	}
+	if res.Count() > 0 && !(res.min <= res.max) {
+		return ErrInvalidEncoding
+	}
+	*t = *res
+	return nil
+}
@@ -0,0 +1,362 @@
+package mycache
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"slices"
+)
+
+// Parameters of the Top-K created by NewTopK with zero values, like TOPK.RESERVE in Redis
+const (
+	DefaultTopKWidth = 8
+	DefaultTopKDepth = 7
+	DefaultTopKDecay = 0.9
+)
+
+// TopK keeps the k most frequent items with the HeavyKeeper algorithm: items share depth rows
+// of width buckets, each holding the fingerprint of an item and its count, and an item that
+// collides with another decays its count with a probability of decay^count, so that infrequent
+// items get evicted while frequent ones keep their buckets.
+type TopK struct {
+	k       int
+	width   int
+	depth   int
+	decay   float64
+	buckets []topKBucket
+	// top is a min-heap of the k items with the largest counts
+	top topKHeap
+	// bytes is the total length of the items in top
+	bytes uint64
+}
+
+type topKBucket struct {
+	fp    uint32
+	count uint32
+}
+
+// TopKItem is an item of a TopK with its estimated count
+type TopKItem struct {
+	Item  string
+	Count uint64
+}
+
+// NewTopK returns an empty TopK keeping k items. width, depth and decay default to
+// DefaultTopKWidth, DefaultTopKDepth and DefaultTopKDecay when they are zero.
+// It returns ErrInvalidDimensions or ErrInvalidDecay if they are out of range.
+func NewTopK(k, width, depth int, decay float64) (*TopK, error) {
+	width, depth, decay, err := topKParameters(k, width, depth, decay)
+	if err != nil {
+		return nil, err
+	}
+	return &TopK{k: k, width: width, depth: depth, decay: decay, buckets: make([]topKBucket, width*depth)}, nil
+}
+
+// topKParameters applies the defaults of NewTopK to width, depth and decay, and checks them
+func topKParameters(k, width, depth int, decay float64) (int, int, float64, error) {
+	if width == 0 {
+		width = DefaultTopKWidth
+	}
+	if depth == 0 {
+		depth = DefaultTopKDepth
+	}
+	if decay == 0 {
+		decay = DefaultTopKDecay
+	}
+	if k <= 0 || k > maxSketchCounters || checkSketchDimensions(width, depth) != nil {
+		return 0, 0, 0, ErrInvalidDimensions
+	}
+	if !(decay > 0 && decay < 1) {
+		return 0, 0, 0, ErrInvalidDecay
+	}
+	return width, depth, decay, nil
+}
+
+func (t *TopK) Size() uint64 {
+	return uint64(8*len(t.buckets)+(stringHeaderSize+8)*len(t.top)) + t.bytes
+}
+
+// Len returns the number of items in the top, at most k
+func (t *TopK) Len() int {
+	return len(t.top)
+}
+
+func (t *TopK) Type() string {
+	return "TopK"
+}
+
+// K returns the number of items kept
+func (t *TopK) K() int {
+	return t.k
+}
+
+// topKHash returns the hash of item, selecting its buckets, and its fingerprint
+func topKHash(item string) (uint64, uint32) {
+	return murmurHash64A([]byte(item), 0), uint32(murmurHash64A([]byte(item), 0xadc83b19))
+}
+
+// index returns the position in buckets of the bucket of row i
+func (t *TopK) index(h uint64, i int) int {
+	h1, h2 := uint32(h), uint32(h>>32)
+	return i*t.width + int((h1+uint32(i)*h2)%uint32(t.width))
+}
+
+// Add adds an occurrence of item. If this moves it into the top and expels another item,
+// it returns the expelled item and true.
+func (t *TopK) Add(item string) (string, bool) {
+	return t.IncrBy(item, 1)
+}
+
+// IncrBy adds incr occurrences of item, see Add
+func (t *TopK) IncrBy(item string, incr uint32) (string, bool) {
+	h, fp := topKHash(item)
+	var count uint32
+	for i := 0; i < t.depth; i++ {
+		b := &t.buckets[t.index(h, i)]
+		switch {
+		case b.count == 0:
+			b.fp, b.count = fp, incr
+		case b.fp == fp:
+			b.count = uint32(min(uint64(b.count)+uint64(incr), math.MaxUint32))
+		default:
+			t.decayBucket(b, fp, incr)
+		}
+		if b.fp == fp {
+			count = max(count, b.count)
+		}
+	}
+	return t.offer(item, uint64(count))
+}
+
+// minTopKDecay is the probability of decay below which a bucket is considered out of reach
+const minTopKDecay = 1e-9
+
+// decayBucket applies incr occurrences of the item with fingerprint fp to b, which holds another item.
+// Each occurrence decays the count of b with a probability of decay^count, and the item takes over b
+// once its count reaches 0. Rather than drawing each occurrence, it draws the number of occurrences
+// until the next decay, so that it loops at most once per decay.
+func (t *TopK) decayBucket(b *topKBucket, fp uint32, incr uint32) {
+	n := float64(incr)
+	for {
+		p := math.Pow(t.decay, float64(b.count))
+		if p < minTopKDecay {
+			return
+		}
+		// the number of occurrences before the next decay follows a geometric distribution
+		skip := math.Floor(math.Log(1-rand.Float64()) / math.Log1p(-p))
+		if skip >= n {
+			return
+		}
+		n -= skip
+		b.count--
+		if b.count == 0 {
+			b.fp, b.count = fp, uint32(n)
+			return
+		}
+		n--
+	}
+}
+
+// offer updates the top with the estimated count of item, and returns the item it expels, if any
+func (t *TopK) offer(item string, count uint64) (string, bool) {
+	if i := t.find(item); i >= 0 {
+		t.top[i].Count = max(t.top[i].Count, count)
+		heap.Fix(&t.top, i)
+		return "", false
+	}
+	if len(t.top) < t.k {
+		heap.Push(&t.top, TopKItem{item, count})
+		t.bytes += uint64(len(item))
+		return "", false
+	}
+	if count <= t.top[0].Count {
+		return "", false
+	}
+	expelled := t.top[0].Item
+	t.bytes += uint64(len(item)) - uint64(len(expelled))
+	t.top[0] = TopKItem{item, count}
+	heap.Fix(&t.top, 0)
+	return expelled, true
+}
+
+func (t *TopK) find(item string) int {
+	return slices.IndexFunc(t.top, func(e TopKItem) bool { return e.Item == item })
+}
+
+// Query returns whether item is in the top
+func (t *TopK) Query(item string) bool {
+	return t.find(item) >= 0
+}
+
+// Count returns the estimated count of item, which may be below its true count
+func (t *TopK) Count(item string) uint64 {
+	h, fp := topKHash(item)
+	var count uint32
+	for i := 0; i < t.depth; i++ {
+		if b := t.buckets[t.index(h, i)]; b.fp == fp {
+			count = max(count, b.count)
+		}
+	}
+	return uint64(count)
+}
+
+// List returns the items in the top, the most frequent first
+func (t *TopK) List() []TopKItem {
+	list := slices.Clone(t.top)
+	slices.SortFunc(list, func(a, b TopKItem) int {
+		if a.Count != b.Count {
+			if a.Count > b.Count {
+				return -1
+			}
+			return 1
+		}
+		if a.Item < b.Item {
+			return -1
+		}
+		return 1
+	})
+	return list
+}
+
+// Merge combines the buckets of others with those of t, and keeps the k items of all the tops
+// with the largest merged counts. Two buckets of the same item add up, while the count of
+// the larger of two different items is reduced by the other.
+// It returns ErrIncompatibleSketch if a TopK has another k, width or depth than t, leaving t unchanged.
+func (t *TopK) Merge(others ...*TopK) error {
+	for _, o := range others {
+		if o.k != t.k || o.width != t.width || o.depth != t.depth {
+			return ErrIncompatibleSketch
+		}
+	}
+
+	candidates := make(map[string]bool)
+	for _, e := range t.top {
+		candidates[e.Item] = true
+	}
+	for _, o := range others {
+		for j, ob := range o.buckets {
+			b := &t.buckets[j]
+			switch {
+			case ob.count == 0:
+			case b.fp == ob.fp || b.count == 0:
+				b.fp = ob.fp
+				b.count = uint32(min(uint64(b.count)+uint64(ob.count), math.MaxUint32))
+			case b.count >= ob.count:
+				b.count -= ob.count
+			default:
+				b.fp, b.count = ob.fp, ob.count-b.count
+			}
+		}
+		for _, e := range o.top {
+			candidates[e.Item] = true
+		}
+	}
+
+	t.top, t.bytes = t.top[:0], 0
+	for item := range candidates {
+		if count := t.Count(item); count > 0 {
+			t.offer(item, count)
+		}
+	}
+	return nil
+}
+
+// Clone returns a copy of t
+func (t *TopK) Clone() *TopK {
+	clone := *t
+	clone.buckets = slices.Clone(t.buckets)
+	clone.top = slices.Clone(t.top)
+	return &clone
+}
+
+// MarshalBinary encodes the TopK as its k, width and depth as uvarints, its decay in 8 bytes,
+// its buckets as pairs of 4 byte fingerprints and counts, and the length of its top followed
+// by each item as its length, its bytes and its count
+func (t *TopK) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, 4*binary.MaxVarintLen64+8+8*len(t.buckets)+int(t.bytes)+2*binary.MaxVarintLen64*len(t.top))
+	data = binary.AppendUvarint(data, uint64(t.k))
+	data = binary.AppendUvarint(data, uint64(t.width))
+	data = binary.AppendUvarint(data, uint64(t.depth))
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(t.decay))
+	for _, b := range t.buckets {
+		data = binary.LittleEndian.AppendUint32(data, b.fp)
+		data = binary.LittleEndian.AppendUint32(data, b.count)
+	}
+	data = binary.AppendUvarint(data, uint64(len(t.top)))
+	for _, e := range t.top {
+		data = binary.AppendUvarint(data, uint64(len(e.Item)))
+		data = append(data, e.Item...)
+		data = binary.AppendUvarint(data, e.Count)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes data produced by MarshalBinary, replacing the content of the TopK.
+// It returns ErrInvalidEncoding if data is malformed.
+func (t *TopK) UnmarshalBinary(data []byte) error {
+	header, data, ok := readUvarints(data, 3)
+	if !ok || len(data) < 8 {
+		return ErrInvalidEncoding
+	}
+	decay := math.Float64frombits(binary.LittleEndian.Uint64(data))
+	if header[0] > maxSketchCounters || header[1] > maxSketchCounters || header[2] > maxSketchCounters {
+		return ErrInvalidEncoding
+	}
+	res, err := NewTopK(int(header[0]), int(header[1]), int(header[2]), decay)
+	if err != nil || header[1] == 0 || header[2] == 0 || decay == 0 {
+		return ErrInvalidEncoding
+	}
+	data = data[8:]
+	if len(data) < 8*len(res.buckets) {
+		return ErrInvalidEncoding
+	}
+	for j := range res.buckets {
+		res.buckets[j] = topKBucket{binary.LittleEndian.Uint32(data[8*j:]), binary.LittleEndian.Uint32(data[8*j+4:])}
+	}
+	data = data[8*len(res.buckets):]
+
+	n, size := binary.Uvarint(data)
+	if size <= 0 || n > uint64(res.k) {
+		return ErrInvalidEncoding
+	}
+	data = data[size:]
+	for ; n > 0; n-- {
+		l, size := binary.Uvarint(data)
+		if size <= 0 || l > uint64(len(data)-size) {
+			return ErrInvalidEncoding
+		}
+		item := string(data[size : size+int(l)])
+		data = data[size+int(l):]
+		count, size := binary.Uvarint(data)
+		if size <= 0 {
+			return ErrInvalidEncoding
+		}
+		data = data[size:]
+		if res.find(item) >= 0 {
+			return ErrInvalidEncoding
+		}
+		heap.Push(&res.top, TopKItem{item, count})
+		res.bytes += uint64(len(item))
+	}
+	if len(data) != 0 {
+		return ErrInvalidEncoding
+	}
+	*t = *res
+	return nil
+}
+
+// topKHeap is a min-heap of items by count
+type topKHeap []TopKItem
+
+func (h topKHeap) Len() int           { return len(h) }
+func (h topKHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+func (h topKHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *topKHeap) Push(x any)        { *h = append(*h, x.(TopKItem)) }
+
+func (h *topKHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
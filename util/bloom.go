@@ -1,60 +1,340 @@
 package util
 
 import (
-	"hash"
-	"hash/fnv"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"sync"
 )
 
+var (
+	// ErrInvalidBloomFilter is returned when unmarshaling data that isn't a valid BloomFilter
+	ErrInvalidBloomFilter = errors.New("invalid bloom filter data")
+	// ErrIncompatibleBloomFilters is returned when combining filters with different sizes or numbers of hash functions
+	ErrIncompatibleBloomFilters = errors.New("bloom filters have different sizes or numbers of hash functions")
+)
+
+// MaxBloomFilterSize is the largest number of bits of a BloomFilter
+const MaxBloomFilterSize = math.MaxUint32
+
+// Bounds of the false positive rates of EstimateParameters, to which other rates are clamped.
+// Without them, a rate of 0 would size the filter at MaxBloomFilterSize, and a rate of 1 at a single bit.
+const (
+	MinFalsePositiveRate = 1e-12
+	MaxFalsePositiveRate = 0.5
+)
+
+// BloomFilter is a set of byte strings that may report elements as present when they weren't added.
+// Its bits are packed in words. A BloomFilter is not safe for concurrent writes, see SyncBloomFilter.
 type BloomFilter struct {
-	bitmap       []bool
-	hashfn       hash.Hash64
+	bitmap       []uint64
 	numHashFuncs int
 	n            int
 	size         int
 }
 
-// NewBloomFilter creates BloomFilter instance
+// NewBloomFilter creates BloomFilter instance with size bits, which is capped at MaxBloomFilterSize
 func NewBloomFilter(numHashFuncs, size int) *BloomFilter {
+	size = min(max(size, 1), MaxBloomFilterSize)
 	return &BloomFilter{
-		bitmap:       make([]bool, size),
-		hashfn:       fnv.New64(),
-		numHashFuncs: numHashFuncs,
+		bitmap:       make([]uint64, (size+63)/64),
+		numHashFuncs: max(numHashFuncs, 1),
 		n:            0,
 		size:         size,
 	}
 }
 
-func (bf *BloomFilter) getHash(b []byte) (uint32, uint32) {
-	bf.hashfn.Reset()
-	bf.hashfn.Write(b)
-	hash64 := bf.hashfn.Sum64()
+// NewWithEstimates creates BloomFilter instance sized for n elements with a false positive rate of fp
+func NewWithEstimates(n int, fp float64) *BloomFilter {
+	size, numHashFuncs := EstimateParameters(n, fp)
+	return NewBloomFilter(numHashFuncs, size)
+}
+
+// EstimateParameters returns the optimal number of bits and of hash functions of a BloomFilter
+// holding n elements with a false positive rate of fp. The number of bits may exceed MaxBloomFilterSize.
+// n is at least 1, and fp is clamped between MinFalsePositiveRate and MaxFalsePositiveRate.
+func EstimateParameters(n int, fp float64) (size, numHashFuncs int) {
+	n = max(n, 1)
+	if !(fp >= MinFalsePositiveRate) {
+		fp = MinFalsePositiveRate
+	}
+	fp = math.Min(fp, MaxFalsePositiveRate)
+	m := math.Ceil(-float64(n) * math.Log(fp) / (math.Ln2 * math.Ln2))
+	m = max(math.Min(m, math.MaxInt64/2), 1)
+	return int(m), max(int(math.Ceil(math.Ln2*m/float64(n))), 1)
+}
+
+// getHash returns the two halves of the 64 bit FNV-1 hash of b
+func getHash(b []byte) (uint32, uint32) {
+	const offset64, prime64 = 14695981039346656037, 1099511628211
+	hash64 := uint64(offset64)
+	for _, c := range b {
+		hash64 *= prime64
+		hash64 ^= uint64(c)
+	}
 	h1 := uint32(hash64 & ((1 << 32) - 1))
 	h2 := uint32(hash64 >> 32)
 	return h1, h2
 }
 
+// location returns the bit set by the i-th hash function
+func (bf *BloomFilter) location(h1, h2 uint32, i int) uint32 {
+	return (h1 + uint32(i)*h2) % uint32(bf.size)
+}
+
+func (bf *BloomFilter) test(index uint32) bool {
+	return bf.bitmap[index/64]&(1<<(index%64)) != 0
+}
+
 // Add adds element to BloomFilter
 func (bf *BloomFilter) Add(b []byte) {
-	h1, h2 := bf.getHash(b)
+	h1, h2 := getHash(b)
 	for i := 0; i < bf.numHashFuncs; i++ {
-		index := (h1 + uint32(i)*h2) % uint32(bf.size)
-		bf.bitmap[index] = true
+		index := bf.location(h1, h2, i)
+		bf.bitmap[index/64] |= 1 << (index % 64)
 	}
 	bf.n++
 }
 
+// TestAndAdd adds element to BloomFilter, and returns true if it was already present.
+// Unlike Add, it doesn't count elements that were present.
+func (bf *BloomFilter) TestAndAdd(b []byte) bool {
+	h1, h2 := getHash(b)
+	present := true
+	for i := 0; i < bf.numHashFuncs; i++ {
+		index := bf.location(h1, h2, i)
+		if !bf.test(index) {
+			present = false
+			bf.bitmap[index/64] |= 1 << (index % 64)
+		}
+	}
+	if !present {
+		bf.n++
+	}
+	return present
+}
+
 // Contains return true if element is in BloomFilter
 func (bf *BloomFilter) Contains(b []byte) bool {
-	h1, h2 := bf.getHash(b)
-	result := true
-	for i := 0; i < bf.numHashFuncs && result; i++ {
-		index := (h1 + uint32(i)*h2) % uint32(bf.size)
-		result = result && bf.bitmap[index]
+	h1, h2 := getHash(b)
+	for i := 0; i < bf.numHashFuncs; i++ {
+		if !bf.test(bf.location(h1, h2, i)) {
+			return false
+		}
 	}
-	return result
+	return true
 }
 
 // GetEleNum returns the number of elements in BloomFilter
 func (bf *BloomFilter) GetEleNum() int {
 	return bf.n
-}
\ No newline at end of file
+}
+
+// Cap returns the number of bits of BloomFilter
+func (bf *BloomFilter) Cap() int {
+	return bf.size
+}
+
+// NumHashFuncs returns the number of hash functions of BloomFilter
+func (bf *BloomFilter) NumHashFuncs() int {
+	return bf.numHashFuncs
+}
+
+// MemSize returns the number of bytes used by the bits of BloomFilter
+func (bf *BloomFilter) MemSize() int {
+	return 8 * len(bf.bitmap)
+}
+
+// FillRatio returns the fraction of bits that are set
+func (bf *BloomFilter) FillRatio() float64 {
+	return float64(bf.ones()) / float64(bf.size)
+}
+
+// EstimatedFalsePositiveRate returns the probability that Contains reports an element
+// that wasn't added, computed from the current fill ratio
+func (bf *BloomFilter) EstimatedFalsePositiveRate() float64 {
+	return math.Pow(bf.FillRatio(), float64(bf.numHashFuncs))
+}
+
+func (bf *BloomFilter) ones() int {
+	n := 0
+	for _, w := range bf.bitmap {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// estimateCount returns the number of elements estimated from the number of bits set
+func (bf *BloomFilter) estimateCount() int {
+	m, k := float64(bf.size), float64(bf.numHashFuncs)
+	ones := float64(bf.ones())
+	if ones >= m {
+		return bf.n
+	}
+	return int(math.Round(-m / k * math.Log(1-ones/m)))
+}
+
+// Union adds the elements of other to BloomFilter, which then reports an element as present
+// if either did. The number of elements becomes an estimate.
+// It returns ErrIncompatibleBloomFilters if the filters have different parameters.
+func (bf *BloomFilter) Union(other *BloomFilter) error {
+	if err := bf.checkCompatible(other); err != nil {
+		return err
+	}
+	for i, w := range other.bitmap {
+		bf.bitmap[i] |= w
+	}
+	bf.n = bf.estimateCount()
+	return nil
+}
+
+// Intersect keeps in BloomFilter the bits also set in other, so that it only reports elements
+// reported by both, plus more false positives than a filter of their common elements.
+// The number of elements becomes an estimate.
+// It returns ErrIncompatibleBloomFilters if the filters have different parameters.
+func (bf *BloomFilter) Intersect(other *BloomFilter) error {
+	if err := bf.checkCompatible(other); err != nil {
+		return err
+	}
+	for i, w := range other.bitmap {
+		bf.bitmap[i] &= w
+	}
+	bf.n = bf.estimateCount()
+	return nil
+}
+
+func (bf *BloomFilter) checkCompatible(other *BloomFilter) error {
+	if bf.size != other.size || bf.numHashFuncs != other.numHashFuncs {
+		return ErrIncompatibleBloomFilters
+	}
+	return nil
+}
+
+// Clone returns a copy of BloomFilter
+func (bf *BloomFilter) Clone() *BloomFilter {
+	c := *bf
+	c.bitmap = append([]uint64(nil), bf.bitmap...)
+	return &c
+}
+
+// MarshalBinary encodes BloomFilter as the number of hash functions, the number of bits
+// and the number of elements as uvarints, followed by the bits packed from the most
+// significant bit of each byte
+func (bf *BloomFilter) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, 3*binary.MaxVarintLen64+(bf.size+7)/8)
+	data = binary.AppendUvarint(data, uint64(bf.numHashFuncs))
+	data = binary.AppendUvarint(data, uint64(bf.size))
+	data = binary.AppendUvarint(data, uint64(bf.n))
+	packed := make([]byte, (bf.size+7)/8)
+	for i := range packed {
+		// bit i of the filter is bit i%64 of a word, and the bit 7-i%8 of a byte
+		packed[i] = bits.Reverse8(byte(bf.bitmap[i/8] >> (8 * (i % 8))))
+	}
+	return append(data, packed...), nil
+}
+
+// UnmarshalBinary decodes data produced by MarshalBinary, replacing the content of BloomFilter
+func (bf *BloomFilter) UnmarshalBinary(data []byte) error {
+	var header [3]uint64
+	for i := range header {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return ErrInvalidBloomFilter
+		}
+		header[i], data = v, data[n:]
+	}
+	numHashFuncs, size, n := header[0], header[1], header[2]
+	if size == 0 || size > MaxBloomFilterSize || numHashFuncs == 0 || numHashFuncs > math.MaxInt32 ||
+		n > math.MaxInt32 || uint64(len(data)) != (size+7)/8 {
+		return ErrInvalidBloomFilter
+	}
+
+	bitmap := make([]uint64, (size+63)/64)
+	for i, c := range data {
+		bitmap[i/8] |= uint64(bits.Reverse8(c)) << (8 * (i % 8))
+	}
+	*bf = BloomFilter{
+		bitmap:       bitmap,
+		numHashFuncs: int(numHashFuncs),
+		n:            int(n),
+		size:         int(size),
+	}
+	return nil
+}
+
+// SyncBloomFilter is a BloomFilter that is safe for concurrent use
+type SyncBloomFilter struct {
+	mu sync.RWMutex
+	bf *BloomFilter
+}
+
+// NewSyncBloomFilter makes bf safe for concurrent use. bf must not be used directly afterwards.
+func NewSyncBloomFilter(bf *BloomFilter) *SyncBloomFilter {
+	return &SyncBloomFilter{bf: bf}
+}
+
+// Add adds element to SyncBloomFilter
+func (s *SyncBloomFilter) Add(b []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bf.Add(b)
+}
+
+// TestAndAdd adds element to SyncBloomFilter, and returns true if it was already present
+func (s *SyncBloomFilter) TestAndAdd(b []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bf.TestAndAdd(b)
+}
+
+// Contains return true if element is in SyncBloomFilter
+func (s *SyncBloomFilter) Contains(b []byte) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bf.Contains(b)
+}
+
+// GetEleNum returns the number of elements in SyncBloomFilter
+func (s *SyncBloomFilter) GetEleNum() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bf.GetEleNum()
+}
+
+// FillRatio returns the fraction of bits that are set
+func (s *SyncBloomFilter) FillRatio() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bf.FillRatio()
+}
+
+// EstimatedFalsePositiveRate returns the probability that Contains reports an element that wasn't added
+func (s *SyncBloomFilter) EstimatedFalsePositiveRate() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bf.EstimatedFalsePositiveRate()
+}
+
+// Union adds the elements of other to SyncBloomFilter, see BloomFilter.Union
+func (s *SyncBloomFilter) Union(other *SyncBloomFilter) error {
+	o := other.Snapshot()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bf.Union(o)
+}
+
+// Intersect keeps the elements of SyncBloomFilter that are also in other, see BloomFilter.Intersect
+func (s *SyncBloomFilter) Intersect(other *SyncBloomFilter) error {
+	o := other.Snapshot()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bf.Intersect(o)
+}
+
+// Snapshot returns a copy of the underlying BloomFilter
+func (s *SyncBloomFilter) Snapshot() *BloomFilter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bf.Clone()
+}
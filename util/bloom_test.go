@@ -0,0 +1,91 @@
+package util
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	bf := NewWithEstimates(1000, 0.01)
+	if bf.MemSize() > 1300 {
+		t.Errorf("got %d bytes, expect at most 1300", bf.MemSize())
+	}
+	sbf := NewSyncBloomFilter(bf)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < 1000; i += 4 {
+				sbf.Add([]byte(strconv.Itoa(i)))
+				sbf.Contains([]byte(strconv.Itoa(i)))
+			}
+		}(g)
+	}
+	wg.Wait()
+	for i := 0; i < 1000; i++ {
+		if !sbf.Contains([]byte(strconv.Itoa(i))) {
+			t.Errorf("%d should be in the filter", i)
+		}
+	}
+	if r := sbf.FillRatio(); math.Abs(r-0.5) > 0.05 {
+		t.Errorf("got fill ratio %f, expect about 0.5", r)
+	}
+	if r := sbf.EstimatedFalsePositiveRate(); math.Abs(r-0.01) > 0.005 {
+		t.Errorf("got false positive rate %f, expect about 0.01", r)
+	}
+
+	a, b := NewWithEstimates(1000, 0.01), NewWithEstimates(1000, 0.01)
+	for i := 0; i < 200; i++ {
+		a.Add([]byte("a" + strconv.Itoa(i)))
+		b.Add([]byte("b" + strconv.Itoa(i)))
+	}
+	a.Add([]byte("both"))
+	b.Add([]byte("both"))
+	union := a.Clone()
+	if err := union.Union(b); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	if !union.Contains([]byte("a1")) || !union.Contains([]byte("b1")) {
+		t.Errorf("union should contain the elements of both filters")
+	}
+	if n := union.GetEleNum(); math.Abs(float64(n)-401) > 20 {
+		t.Errorf("got %d, expect about 401", n)
+	}
+	if err := a.Intersect(b); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	if !a.Contains([]byte("both")) {
+		t.Errorf("intersection should contain the common elements")
+	}
+	if err := a.Union(NewWithEstimates(10, 0.01)); err != ErrIncompatibleBloomFilters {
+		t.Errorf("got %v, expect %v", err, ErrIncompatibleBloomFilters)
+	}
+
+	data, _ := union.MarshalBinary()
+	c := new(BloomFilter)
+	if err := c.UnmarshalBinary(data); err != nil {
+		t.Errorf("got %v, expect nil", err)
+	}
+	if !c.Contains([]byte("a199")) || c.FillRatio() != union.FillRatio() {
+		t.Errorf("unmarshaled filter differs from the original")
+	}
+}
+
+func TestEstimateParameters(t *testing.T) {
+	for _, fp := range []float64{0, -1, math.NaN()} {
+		if size, _ := EstimateParameters(1000, fp); size > 60*1000 {
+			t.Errorf("fp %f: got %d bits, expect at most %d", fp, size, 60*1000)
+		}
+	}
+	for _, fp := range []float64{1, 2} {
+		if size, _ := EstimateParameters(1000, fp); size < 1000 {
+			t.Errorf("fp %f: got %d bits, expect at least 1000", fp, size)
+		}
+	}
+	if size, _ := EstimateParameters(0, 0.01); size != 10 {
+		t.Errorf("got %d bits, expect 10 for a single element", size)
+	}
+}
@@ -0,0 +1,84 @@
+package util
+
+import "math"
+
+// CountingBloomFilter is a Bloom filter whose bits are 8 bit counters, so that elements can be removed.
+// A counter that reaches 255 is never decremented again.
+type CountingBloomFilter struct {
+	counters     []uint8
+	numHashFuncs int
+	n            int
+	size         int
+}
+
+// NewCountingBloomFilter creates CountingBloomFilter instance with size counters,
+// which is capped at MaxBloomFilterSize
+func NewCountingBloomFilter(numHashFuncs, size int) *CountingBloomFilter {
+	size = min(max(size, 1), MaxBloomFilterSize)
+	return &CountingBloomFilter{
+		counters:     make([]uint8, size),
+		numHashFuncs: max(numHashFuncs, 1),
+		size:         size,
+	}
+}
+
+// NewCountingWithEstimates creates CountingBloomFilter instance sized for n elements
+// with a false positive rate of fp
+func NewCountingWithEstimates(n int, fp float64) *CountingBloomFilter {
+	size, numHashFuncs := EstimateParameters(n, fp)
+	return NewCountingBloomFilter(numHashFuncs, size)
+}
+
+// location returns the counter of the i-th hash function
+func (cf *CountingBloomFilter) location(h1, h2 uint32, i int) uint32 {
+	return (h1 + uint32(i)*h2) % uint32(cf.size)
+}
+
+// Add adds element to CountingBloomFilter
+func (cf *CountingBloomFilter) Add(b []byte) {
+	h1, h2 := getHash(b)
+	for i := 0; i < cf.numHashFuncs; i++ {
+		index := cf.location(h1, h2, i)
+		if cf.counters[index] < math.MaxUint8 {
+			cf.counters[index]++
+		}
+	}
+	cf.n++
+}
+
+// Contains return true if element is in CountingBloomFilter
+func (cf *CountingBloomFilter) Contains(b []byte) bool {
+	h1, h2 := getHash(b)
+	for i := 0; i < cf.numHashFuncs; i++ {
+		if cf.counters[cf.location(h1, h2, i)] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Remove removes element from CountingBloomFilter, and returns false if it wasn't present
+func (cf *CountingBloomFilter) Remove(b []byte) bool {
+	if !cf.Contains(b) {
+		return false
+	}
+	h1, h2 := getHash(b)
+	for i := 0; i < cf.numHashFuncs; i++ {
+		index := cf.location(h1, h2, i)
+		if cf.counters[index] < math.MaxUint8 {
+			cf.counters[index]--
+		}
+	}
+	cf.n--
+	return true
+}
+
+// GetEleNum returns the number of elements in CountingBloomFilter
+func (cf *CountingBloomFilter) GetEleNum() int {
+	return cf.n
+}
+
+// MemSize returns the number of bytes used by the counters of CountingBloomFilter
+func (cf *CountingBloomFilter) MemSize() int {
+	return len(cf.counters)
+}
@@ -0,0 +1,163 @@
+package util
+
+import "math/rand"
+
+const (
+	// cuckooBucketSize is the number of fingerprints in a bucket
+	cuckooBucketSize = 4
+	// cuckooMaxKicks is the number of fingerprints relocated before an insertion gives up
+	cuckooMaxKicks = 500
+)
+
+// CuckooFilter stores 16 bit fingerprints of its elements in one of two buckets, which makes it
+// smaller than a Bloom filter with the same false positive rate, of about 0.012%, and lets it
+// remove elements. When its table is full, it adds a table twice as large.
+type CuckooFilter struct {
+	tables []*cuckooTable
+	n      int
+}
+
+type cuckooTable struct {
+	slots []uint16
+	// mask selects a bucket, the number of buckets being a power of two
+	mask uint64
+}
+
+// NewCuckooFilter creates CuckooFilter instance whose first table holds about capacity elements
+func NewCuckooFilter(capacity int) *CuckooFilter {
+	buckets := uint64(1)
+	for buckets*cuckooBucketSize < uint64(max(capacity, 1)) {
+		buckets <<= 1
+	}
+	return &CuckooFilter{tables: []*cuckooTable{newCuckooTable(buckets)}}
+}
+
+func newCuckooTable(buckets uint64) *cuckooTable {
+	return &cuckooTable{slots: make([]uint16, buckets*cuckooBucketSize), mask: buckets - 1}
+}
+
+// cuckooHash returns the hash of b and its fingerprint, which is never 0
+func cuckooHash(b []byte) (uint64, uint16) {
+	h1, h2 := getHash(b)
+	h := mix64(uint64(h2)<<32 | uint64(h1))
+	return h, max(uint16(h>>48), 1)
+}
+
+// mix64 is the finalizer of MurmurHash3, which makes every bit of the result depend on all of x
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// alt returns the other bucket of the fingerprint fp stored in bucket i
+func (t *cuckooTable) alt(i uint64, fp uint16) uint64 {
+	return (i ^ mix64(uint64(fp))) & t.mask
+}
+
+func (t *cuckooTable) bucket(i uint64) []uint16 {
+	return t.slots[i*cuckooBucketSize : (i+1)*cuckooBucketSize]
+}
+
+// put stores fp in a free slot of bucket i, and returns false if there is none
+func (t *cuckooTable) put(i uint64, fp uint16) bool {
+	b := t.bucket(i)
+	for j := range b {
+		if b[j] == 0 {
+			b[j] = fp
+			return true
+		}
+	}
+	return false
+}
+
+// find returns the index in slots of fp in bucket i or in its other bucket, or -1
+func (t *cuckooTable) find(h uint64, fp uint16) int {
+	i1 := h & t.mask
+	for _, i := range [2]uint64{i1, t.alt(i1, fp)} {
+		for j, s := range t.bucket(i) {
+			if s == fp {
+				return int(i)*cuckooBucketSize + j
+			}
+		}
+	}
+	return -1
+}
+
+// insert stores fp in one of its buckets, relocating other fingerprints to their other bucket
+// if both are full. It returns false and leaves the table unchanged if it fails.
+func (t *cuckooTable) insert(h uint64, fp uint16) bool {
+	i := h & t.mask
+	if t.put(i, fp) || t.put(t.alt(i, fp), fp) {
+		return true
+	}
+
+	var path [cuckooMaxKicks]int
+	for k := range path {
+		pos := int(i)*cuckooBucketSize + rand.Intn(cuckooBucketSize)
+		path[k] = pos
+		fp, t.slots[pos] = t.slots[pos], fp
+		i = t.alt(i, fp)
+		if t.put(i, fp) {
+			return true
+		}
+	}
+	// undo the relocations
+	for k := len(path) - 1; k >= 0; k-- {
+		fp, t.slots[path[k]] = t.slots[path[k]], fp
+	}
+	return false
+}
+
+// Add adds element to CuckooFilter
+func (cf *CuckooFilter) Add(b []byte) {
+	h, fp := cuckooHash(b)
+	last := cf.tables[len(cf.tables)-1]
+	if !last.insert(h, fp) {
+		last = newCuckooTable(2 * (last.mask + 1))
+		cf.tables = append(cf.tables, last)
+		last.insert(h, fp)
+	}
+	cf.n++
+}
+
+// Contains return true if element is in CuckooFilter
+func (cf *CuckooFilter) Contains(b []byte) bool {
+	h, fp := cuckooHash(b)
+	for _, t := range cf.tables {
+		if t.find(h, fp) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove removes one copy of element from CuckooFilter, and returns false if it wasn't present
+func (cf *CuckooFilter) Remove(b []byte) bool {
+	h, fp := cuckooHash(b)
+	for k := len(cf.tables) - 1; k >= 0; k-- {
+		if pos := cf.tables[k].find(h, fp); pos >= 0 {
+			cf.tables[k].slots[pos] = 0
+			cf.n--
+			return true
+		}
+	}
+	return false
+}
+
+// GetEleNum returns the number of elements in CuckooFilter
+func (cf *CuckooFilter) GetEleNum() int {
+	return cf.n
+}
+
+// MemSize returns the number of bytes used by the fingerprints of CuckooFilter
+func (cf *CuckooFilter) MemSize() int {
+	size := 0
+	for _, t := range cf.tables {
+		size += 2 * len(t.slots)
+	}
+	return size
+}
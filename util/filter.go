@@ -0,0 +1,26 @@
+package util
+
+// Filter is a set of byte strings that may report elements as present when they weren't added,
+// but never misses an element that was added
+type Filter interface {
+	Add(b []byte)
+	Contains(b []byte) bool
+	// GetEleNum returns the number of elements, which may be an estimate
+	GetEleNum() int
+}
+
+// DeletableFilter is a Filter whose elements can be removed
+type DeletableFilter interface {
+	Filter
+	// Remove removes an element that was added, and returns false if it wasn't present.
+	// Removing an element that wasn't added may remove another one.
+	Remove(b []byte) bool
+}
+
+var (
+	_ Filter          = (*BloomFilter)(nil)
+	_ Filter          = (*SyncBloomFilter)(nil)
+	_ Filter          = (*ScalableBloomFilter)(nil)
+	_ DeletableFilter = (*CountingBloomFilter)(nil)
+	_ DeletableFilter = (*CuckooFilter)(nil)
+)
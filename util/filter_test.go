@@ -0,0 +1,64 @@
+package util
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	filters := map[string]Filter{
+		"scalable": NewScalableBloomFilter(100, 0.01),
+		"counting": NewCountingWithEstimates(10000, 0.01),
+		"cuckoo":   NewCuckooFilter(100),
+	}
+	for name, f := range filters {
+		for i := 0; i < 10000; i++ {
+			f.Add([]byte(strconv.Itoa(i)))
+		}
+		// the scalable filter doesn't count the false positives among the added elements
+		if n := f.GetEleNum(); n > 10000 || n < 9800 {
+			t.Errorf("%s: got %d, expect about 10000", name, n)
+		}
+		fp := 0
+		for i := 0; i < 10000; i++ {
+			if !f.Contains([]byte(strconv.Itoa(i))) {
+				t.Errorf("%s: %d should be in the filter", name, i)
+			}
+			if f.Contains([]byte("other" + strconv.Itoa(i))) {
+				fp++
+			}
+		}
+		if fp > 200 {
+			t.Errorf("%s: got %d false positives, expect at most 1%%", name, fp)
+		}
+
+		d, ok := f.(DeletableFilter)
+		if !ok {
+			continue
+		}
+		for i := 0; i < 5000; i++ {
+			if !d.Remove([]byte(strconv.Itoa(i))) {
+				t.Errorf("%s: %d should be removed", name, i)
+			}
+		}
+		for i := 5000; i < 10000; i++ {
+			if !d.Contains([]byte(strconv.Itoa(i))) {
+				t.Errorf("%s: %d should be in the filter", name, i)
+			}
+		}
+		if n := d.GetEleNum(); n != 5000 {
+			t.Errorf("%s: got %d, expect 5000", name, n)
+		}
+	}
+
+	s := filters["scalable"].(*ScalableBloomFilter)
+	if s.Slices() < 5 {
+		t.Errorf("got %d slices, expect at least 5", s.Slices())
+	}
+	if r := s.EstimatedFalsePositiveRate(); r > 0.015 {
+		t.Errorf("got false positive rate %f, expect about 0.01", r)
+	}
+	if c := filters["cuckoo"].(*CuckooFilter); c.MemSize() >= filters["counting"].(*CountingBloomFilter).MemSize() {
+		t.Errorf("cuckoo filter should be smaller than counting bloom filter")
+	}
+}
@@ -0,0 +1,85 @@
+package util
+
+// Match reports whether s matches the glob-style pattern, with the syntax of Redis' MATCH:
+// '*' matches any sequence, '?' matches any single byte, "[abc]", "[^abc]" and "[a-z]"
+// match classes of bytes, and '\' escapes the next byte.
+// On a mismatch, it backtracks to the last '*' only, making it match one more byte:
+// earlier stars never need to be retried, so matching takes O(len(pattern)*len(s)).
+func Match(pattern, s string) bool {
+	var starPattern, starS string
+	star := false
+	for len(pattern) > 0 || len(s) > 0 {
+		if len(pattern) > 0 && pattern[0] == '*' {
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			starPattern, starS, star = pattern, s, true
+			continue
+		}
+		if rest, ok := matchByte(pattern, s); ok {
+			pattern, s = rest, s[1:]
+			continue
+		}
+		if !star || len(starS) == 0 {
+			return false
+		}
+		starS = starS[1:]
+		pattern, s = starPattern, starS
+	}
+	return true
+}
+
+// matchByte matches the first byte of s against the token at the start of pattern, which isn't '*'.
+// It returns the pattern following the token and whether it matched.
+func matchByte(pattern, s string) (string, bool) {
+	if len(pattern) == 0 || len(s) == 0 {
+		return pattern, false
+	}
+	switch pattern[0] {
+	case '?':
+		return pattern[1:], true
+	case '[':
+		return matchClass(pattern[1:], s[0])
+	case '\\':
+		if len(pattern) >= 2 {
+			pattern = pattern[1:]
+		}
+	}
+	return pattern[1:], pattern[0] == s[0]
+}
+
+// matchClass matches c against the class at the start of pattern, just after '['.
+// It returns the pattern following the class and whether c matched.
+func matchClass(pattern string, c byte) (string, bool) {
+	not := len(pattern) > 0 && pattern[0] == '^'
+	if not {
+		pattern = pattern[1:]
+	}
+
+	match := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) >= 2:
+			match = match || pattern[1] == c
+			pattern = pattern[2:]
+		case len(pattern) >= 3 && pattern[1] == '-' && pattern[2] != ']':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			match = match || (c >= lo && c <= hi)
+			pattern = pattern[3:]
+		default:
+			match = match || pattern[0] == c
+			pattern = pattern[1:]
+		}
+	}
+	// skip the closing bracket, an unterminated class ends the pattern
+	if len(pattern) > 0 {
+		pattern = pattern[1:]
+	}
+	return pattern, match != not
+}
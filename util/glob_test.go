@@ -0,0 +1,48 @@
+package util
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"", "", true},
+		{"*", "", true},
+		{"*", "abc", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abcd", false},
+		{"*a*b", "xaxaxb", true},
+		{"*a*b", "xaxax", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"field:[12]?", "field:15", true},
+		{"**a", "ba", true},
+	}
+	for _, test := range tests {
+		if got := Match(test.pattern, test.s); got != test.match {
+			t.Errorf("Match(%q, %q) = %t, expect %t", test.pattern, test.s, got, test.match)
+		}
+	}
+}
+
+func TestMatchBacktracking(t *testing.T) {
+	pattern := strings.Repeat("*a", 20) + "*b"
+	s := strings.Repeat("a", 10000)
+	start := time.Now()
+	if Match(pattern, s) {
+		t.Errorf("got true, expect false")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("took %v, expect linear backtracking", d)
+	}
+}
@@ -0,0 +1,96 @@
+package util
+
+import "math"
+
+const (
+	// ScalableGrowth is the ratio between the capacities of consecutive slices of a ScalableBloomFilter
+	ScalableGrowth = 2
+	// ScalableTightening is the ratio between the false positive rates of consecutive slices
+	ScalableTightening = 0.5
+)
+
+// ScalableBloomFilter is a Bloom filter that adds a slice, a larger BloomFilter with a tighter
+// false positive rate, each time the last one reaches its capacity, so that its overall false
+// positive rate stays below the one it was created with however many elements it holds.
+type ScalableBloomFilter struct {
+	filters    []*BloomFilter
+	capacities []int
+	fps        []float64
+	n          int
+}
+
+// NewScalableBloomFilter creates ScalableBloomFilter instance whose first slice holds capacity elements,
+// with an overall false positive rate of fp
+func NewScalableBloomFilter(capacity int, fp float64) *ScalableBloomFilter {
+	s := &ScalableBloomFilter{}
+	// the rates of the slices sum to at most fp: fp * (1-r) * (1 + r + r^2 + ...)
+	s.grow(max(capacity, 1), fp*(1-ScalableTightening))
+	return s
+}
+
+func (s *ScalableBloomFilter) grow(capacity int, fp float64) {
+	s.filters = append(s.filters, NewWithEstimates(capacity, fp))
+	s.capacities = append(s.capacities, capacity)
+	s.fps = append(s.fps, fp)
+}
+
+// Add adds element to ScalableBloomFilter
+func (s *ScalableBloomFilter) Add(b []byte) {
+	s.TestAndAdd(b)
+}
+
+// TestAndAdd adds element to ScalableBloomFilter, and returns true if it was already present,
+// in which case it isn't counted
+func (s *ScalableBloomFilter) TestAndAdd(b []byte) bool {
+	if s.Contains(b) {
+		return true
+	}
+	last := len(s.filters) - 1
+	if s.filters[last].GetEleNum() >= s.capacities[last] {
+		s.grow(s.capacities[last]*ScalableGrowth, s.fps[last]*ScalableTightening)
+		last++
+	}
+	s.filters[last].Add(b)
+	s.n++
+	return false
+}
+
+// Contains return true if element is in ScalableBloomFilter
+func (s *ScalableBloomFilter) Contains(b []byte) bool {
+	// the last slices are the largest, and the most likely to hold recent elements
+	for i := len(s.filters) - 1; i >= 0; i-- {
+		if s.filters[i].Contains(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetEleNum returns the number of elements in ScalableBloomFilter
+func (s *ScalableBloomFilter) GetEleNum() int {
+	return s.n
+}
+
+// Slices returns the number of slices of ScalableBloomFilter
+func (s *ScalableBloomFilter) Slices() int {
+	return len(s.filters)
+}
+
+// MemSize returns the number of bytes used by the bits of ScalableBloomFilter
+func (s *ScalableBloomFilter) MemSize() int {
+	size := 0
+	for _, f := range s.filters {
+		size += f.MemSize()
+	}
+	return size
+}
+
+// EstimatedFalsePositiveRate returns the probability that Contains reports an element
+// that wasn't added, computed from the fill ratios of the slices
+func (s *ScalableBloomFilter) EstimatedFalsePositiveRate() float64 {
+	none := 1.0
+	for _, f := range s.filters {
+		none *= 1 - f.EstimatedFalsePositiveRate()
+	}
+	return math.Max(1-none, 0)
+}
@@ -1,22 +1,192 @@
 package mycache
 
 import (
+	"cmp"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
 	"github.com/RGBli/MyCache/skiplist"
 )
 
+// Zset is a sorted set of unique members, ordered by score and then by member.
+// Small sorted sets are stored compactly as a sorted array, see ZsetMaxListpackEntries.
 type Zset struct {
-	list *skiplist.SkipList
+	// small holds the members of a listpack, in order
+	small []ZMember
+	// dict and list are only set for the skiplist encoding
+	dict map[string]float64
+	list *skiplist.SkipList[ZMember, struct{}]
+	// members is the total length of the members
+	members uint64
+}
+
+// ZMember is a member of a Zset with its score
+type ZMember struct {
+	Score  float64
+	Member string
+}
+
+// compareZMembers orders members by score, and then by member.
+func compareZMembers(a, b ZMember) int {
+	if a.Score != b.Score {
+		return cmp.Compare(a.Score, b.Score)
+	}
+	return strings.Compare(a.Member, b.Member)
+}
+
+// ZAddOptions are the flags of ZADD.
+// NX only adds new members, XX only updates existing members, GT and LT only update
+// existing members if the new score is greater or less than the current score, and
+// CH makes ZAdd count changed members instead of added members.
+type ZAddOptions struct {
+	NX bool
+	XX bool
+	GT bool
+	LT bool
+	CH bool
+}
+
+// Aggregate is the way ZUnionStore and ZInterStore combine the scores of a member
+type Aggregate int
+
+const (
+	AggregateSum Aggregate = iota
+	AggregateMin
+	AggregateMax
+)
+
+func (agg Aggregate) apply(a, b float64) float64 {
+	switch agg {
+	case AggregateMin:
+		return math.Min(a, b)
+	case AggregateMax:
+		return math.Max(a, b)
+	}
+	// like Redis, +inf plus -inf is 0
+	if sum := a + b; !math.IsNaN(sum) {
+		return sum
+	}
+	return 0
+}
+
+// ZStoreOptions are the options of ZUnionStore and ZInterStore.
+// Weights multiply the scores of each source, and default to 1.
+type ZStoreOptions struct {
+	Weights   []float64
+	Aggregate Aggregate
+}
+
+func (opts ZAddOptions) validate() error {
+	if opts.NX && (opts.XX || opts.GT || opts.LT) || opts.GT && opts.LT {
+		return ErrIncompatibleOptions
+	}
+	return nil
+}
+
+// ScoreBound is an inclusive or exclusive bound of a score range
+type ScoreBound struct {
+	Score     float64
+	Exclusive bool
+}
+
+// ParseScoreBound parses a bound in Redis syntax, such as "1.5", "(1.5", "-inf" or "+inf"
+func ParseScoreBound(s string) (ScoreBound, error) {
+	var b ScoreBound
+	if len(s) > 0 && s[0] == '(' {
+		b.Exclusive = true
+		s = s[1:]
+	}
+	score, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(score) {
+		return b, ErrNotFloat
+	}
+	b.Score = score
+	return b, nil
+}
+
+// LexBound is an inclusive or exclusive bound of a range of members, see ParseLexBound
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	// inf is -1 for MinLex, 1 for MaxLex and 0 otherwise
+	inf int
+}
+
+var (
+	// MinLex is the bound "-", lower than any member
+	MinLex = LexBound{inf: -1}
+	// MaxLex is the bound "+", greater than any member
+	MaxLex = LexBound{inf: 1}
+)
+
+// ParseLexBound parses a bound in Redis syntax, such as "[a", "(a", "-" or "+"
+func ParseLexBound(s string) (LexBound, error) {
+	switch {
+	case s == "-":
+		return MinLex, nil
+	case s == "+":
+		return MaxLex, nil
+	case len(s) > 0 && s[0] == '[':
+		return LexBound{Value: s[1:]}, nil
+	case len(s) > 0 && s[0] == '(':
+		return LexBound{Value: s[1:], Exclusive: true}, nil
+	}
+	return LexBound{}, ErrInvalidLexBound
+}
+
+// before reports whether member sorts before the range starting at b.
+func (b LexBound) before(member string) bool {
+	if b.inf != 0 {
+		return b.inf > 0
+	}
+	return member < b.Value || (b.Exclusive && member == b.Value)
+}
+
+// upTo reports whether member sorts before or within the range ending at b.
+func (b LexBound) upTo(member string) bool {
+	if b.inf != 0 {
+		return b.inf > 0
+	}
+	return member < b.Value || (!b.Exclusive && member == b.Value)
 }
 
 func NewZset() *Zset {
-	return &Zset{list: skiplist.New()}
+	return &Zset{}
 }
 
+// zmemberSize is the size of a ZMember, besides the member itself
+const zmemberSize = 8 + stringHeaderSize
+
 func (z *Zset) Size() uint64 {
-	return z.list.Size()
+	if z.list == nil {
+		return uint64(sliceHeaderSize+zmemberSize*len(z.small)) + z.members
+	}
+	// each member is both a key of the list and an entry of the dict
+	return z.list.Size() + z.members + uint64((2*zmemberSize+mapEntryOverhead)*len(z.dict))
+}
+
+// Bounds of the size of the skiplist encoding, see Zset.maxSizeWith
+const (
+	maxSkipListSize   = 48 + 24*skiplist.DefaultMaxLevel
+	maxZsetMemberSize = 8 + 16*skiplist.DefaultMaxLevel + 2*zmemberSize + mapEntryOverhead
+)
+
+// maxSizeWith returns an upper bound of the size of the sorted set once n members of bytes total length
+// are added or updated, whatever encoding it converts to. Updated members are reinserted in the skiplist.
+func (z *Zset) maxSizeWith(n int, bytes uint64) uint64 {
+	if z.list != nil {
+		return z.Size() + bytes + uint64(maxZsetMemberSize*n)
+	}
+	return z.members + bytes + uint64(maxSkipListSize+maxZsetMemberSize*(z.Len()+n))
 }
 
 func (z *Zset) Len() int {
+	if z.list == nil {
+		return len(z.small)
+	}
 	return z.list.Len()
 }
 
@@ -24,29 +194,425 @@ func (z *Zset) Type() string {
 	return "Zset"
 }
 
-func (z *Zset) Add(score float64, value string) {
-	z.list.Set(score, value)
+// Encoding returns how the sorted set is stored: EncodingListpack or EncodingSkiplist
+func (z *Zset) Encoding() string {
+	if z.list == nil {
+		return EncodingListpack
+	}
+	return EncodingSkiplist
+}
+
+// score returns the score of member
+func (z *Zset) score(member string) (float64, bool) {
+	if z.list == nil {
+		for _, m := range z.small {
+			if m.Member == member {
+				return m.Score, true
+			}
+		}
+		return 0, false
+	}
+	score, ok := z.dict[member]
+	return score, ok
+}
+
+// scores returns the scores of all the members, which must not be modified
+func (z *Zset) scores() map[string]float64 {
+	if z.list != nil {
+		return z.dict
+	}
+	scores := make(map[string]float64, len(z.small))
+	for _, m := range z.small {
+		scores[m.Member] = m.Score
+	}
+	return scores
+}
+
+// insert adds m, whose member must not be in the set, converting to a skiplist if needed
+func (z *Zset) insert(m ZMember) {
+	z.members += uint64(len(m.Member))
+	if z.list == nil {
+		if len(z.small) < ZsetMaxListpackEntries && len(m.Member) <= ZsetMaxListpackValue {
+			i, _ := slices.BinarySearchFunc(z.small, m, compareZMembers)
+			z.small = slices.Insert(z.small, i, m)
+			return
+		}
+		z.convert()
+	}
+	z.dict[m.Member] = m.Score
+	z.list.Set(m, struct{}{})
+}
+
+// convert moves the members of a listpack to a skiplist
+func (z *Zset) convert() {
+	z.dict = make(map[string]float64, len(z.small)+1)
+	z.list = skiplist.NewFunc[ZMember, struct{}](compareZMembers)
+	for _, m := range z.small {
+		z.dict[m.Member] = m.Score
+		z.list.Set(m, struct{}{})
+	}
+	z.small = nil
+}
+
+// delete removes m, which must be in the set
+func (z *Zset) delete(m ZMember) {
+	z.members -= uint64(len(m.Member))
+	if z.list == nil {
+		if i, found := slices.BinarySearchFunc(z.small, m, compareZMembers); found {
+			z.small = slices.Delete(z.small, i, i+1)
+		}
+		return
+	}
+	z.list.Remove(m)
+	delete(z.dict, m.Member)
 }
 
+// search returns the rank of the first member for which before returns false.
+// before must hold for a prefix of the set.
+func (z *Zset) search(before func(ZMember) bool) int {
+	if z.list == nil {
+		return sort.Search(len(z.small), func(i int) bool {
+			return !before(z.small[i])
+		})
+	}
+	_, rank := z.list.Search(func(n *skiplist.Node[ZMember, struct{}]) bool {
+		return before(n.Key())
+	})
+	return rank
+}
+
+// Add sets the score of value, and returns true if value is a new member
+func (z *Zset) Add(score float64, value string) bool {
+	_, res, _ := z.add(ZAddOptions{}, score, value, false)
+	return res == zaddAdded
+}
+
+// zaddResult is the outcome of adding a single member to a Zset.
+type zaddResult int
+
+const (
+	zaddSkipped zaddResult = iota // the options prevented the update
+	zaddUnchanged
+	zaddUpdated
+	zaddAdded
+)
+
+// add applies ZADD semantics to a single member, adding score to the current score
+// if incr is set. It returns the resulting score and the outcome.
+// The member is left untouched if the score is or becomes NaN, in which case ErrNaN is returned.
+func (z *Zset) add(opts ZAddOptions, score float64, member string, incr bool) (float64, zaddResult, error) {
+	if math.IsNaN(score) {
+		return 0, zaddSkipped, ErrNaN
+	}
+
+	cur, ok := z.score(member)
+	if !ok {
+		if opts.XX {
+			return 0, zaddSkipped, nil
+		}
+		z.insert(ZMember{score, member})
+		return score, zaddAdded, nil
+	}
+
+	if opts.NX {
+		return cur, zaddSkipped, nil
+	}
+	if incr {
+		score += cur
+		if math.IsNaN(score) {
+			return 0, zaddSkipped, ErrNaN
+		}
+	}
+	if (opts.GT && score <= cur) || (opts.LT && score >= cur) {
+		return cur, zaddSkipped, nil
+	}
+	if score == cur {
+		return cur, zaddUnchanged, nil
+	}
+
+	z.delete(ZMember{cur, member})
+	z.insert(ZMember{score, member})
+	return score, zaddUpdated, nil
+}
+
+// Get returns the first member with the given score
 func (z *Zset) Get(score float64) (string, bool) {
-	value := z.list.Get(score)
-	if value != nil {
-		return value.Value(), true
+	rank := z.search(func(m ZMember) bool {
+		return m.Score < score
+	})
+	if members := z.collect(rank, 1); len(members) == 1 && members[0].Score == score {
+		return members[0].Member, true
 	}
 	return "", false
 }
 
+// Score returns the score of member
+func (z *Zset) Score(member string) (float64, bool) {
+	return z.score(member)
+}
+
+// IncrBy increments the score of member by increment, adding member if needed,
+// and returns the new score
+func (z *Zset) IncrBy(increment float64, member string) (float64, error) {
+	score, _, err := z.add(ZAddOptions{}, increment, member, true)
+	return score, err
+}
+
+// GetRange returns the members with a score in [start, end).
+//
+// Deprecated: step is ignored, use RangeByScore instead.
 func (z *Zset) GetRange(start, end, step float64) []string {
-	strs := make([]string, 0)
-	for i := start; i < end; i += step {
-		value := z.list.Get(i)
-		if value != nil {
-			strs = append(strs, value.Value())
-		}
+	members := z.RangeByScore(ScoreBound{Score: start}, ScoreBound{Score: end, Exclusive: true}, 0, -1)
+	strs := make([]string, len(members))
+	for i, m := range members {
+		strs[i] = m.Member
 	}
 	return strs
 }
 
+// scoreRange returns the ranks [lo, hi) of the members with a score between min and max.
+func (z *Zset) scoreRange(min, max ScoreBound) (int, int) {
+	lo := z.search(func(m ZMember) bool {
+		return m.Score < min.Score || (min.Exclusive && m.Score == min.Score)
+	})
+	hi := z.search(func(m ZMember) bool {
+		return m.Score < max.Score || (!max.Exclusive && m.Score == max.Score)
+	})
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
+// collect returns n members starting at rank.
+func (z *Zset) collect(rank, n int) []ZMember {
+	if z.list == nil {
+		end := min(rank+n, len(z.small))
+		if rank >= end {
+			return []ZMember{}
+		}
+		return slices.Clone(z.small[rank:end])
+	}
+
+	members := make([]ZMember, 0, n)
+	for node := z.list.GetByRank(rank); node != nil && len(members) < n; node = node.Next() {
+		members = append(members, node.Key())
+	}
+	return members
+}
+
+// limit applies an offset and a count to the ranks [lo, hi), where a negative count means no limit.
+func limit(lo, hi, offset, count int) (int, int) {
+	if offset < 0 {
+		return lo, lo
+	}
+	if offset >= hi-lo {
+		return hi, hi
+	}
+	lo += offset
+	if count >= 0 && count < hi-lo {
+		hi = lo + count
+	}
+	return lo, hi
+}
+
+// collectReverse returns n members starting at rank, walking towards the lowest score.
+func (z *Zset) collectReverse(rank, n int) []ZMember {
+	if z.list == nil {
+		members := make([]ZMember, 0, n)
+		for i := rank; i >= 0 && i < len(z.small) && len(members) < n; i-- {
+			members = append(members, z.small[i])
+		}
+		return members
+	}
+
+	members := make([]ZMember, 0, n)
+	for node := z.list.GetByRank(rank); node != nil && len(members) < n; node = node.Prev() {
+		members = append(members, node.Key())
+	}
+	return members
+}
+
+// lexRange returns the ranks [lo, hi) of the members between min and max.
+// Like in Redis, the result is only meaningful if all members have the same score.
+func (z *Zset) lexRange(min, max LexBound) (int, int) {
+	lo := z.search(func(m ZMember) bool {
+		return min.before(m.Member)
+	})
+	hi := z.search(func(m ZMember) bool {
+		return max.upTo(m.Member)
+	})
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
+// removeRange removes the members between the ranks lo and hi, hi excluded.
+func (z *Zset) removeRange(lo, hi int) int {
+	if z.list == nil {
+		lo, hi = max(lo, 0), min(hi, len(z.small))
+		if lo >= hi {
+			return 0
+		}
+		for _, m := range z.small[lo:hi] {
+			z.members -= uint64(len(m.Member))
+		}
+		z.small = slices.Delete(z.small, lo, hi)
+		return hi - lo
+	}
+
+	removed := z.list.RemoveRangeByRank(lo, hi)
+	for _, node := range removed {
+		delete(z.dict, node.Key().Member)
+		z.members -= uint64(len(node.Key().Member))
+	}
+	return len(removed)
+}
+
+// Count returns the number of members with a score between min and max
+func (z *Zset) Count(min, max ScoreBound) int {
+	lo, hi := z.scoreRange(min, max)
+	return hi - lo
+}
+
+// RangeByScore returns the members with a score between min and max, in ascending order.
+// It skips offset members and returns at most count members, or all of them if count is negative.
+func (z *Zset) RangeByScore(min, max ScoreBound, offset, count int) []ZMember {
+	lo, hi := z.scoreRange(min, max)
+	lo, hi = limit(lo, hi, offset, count)
+	return z.collect(lo, hi-lo)
+}
+
+// RevRangeByScore is like RangeByScore, but in descending order.
+func (z *Zset) RevRangeByScore(max, min ScoreBound, offset, count int) []ZMember {
+	lo, hi := z.scoreRange(min, max)
+	// limit the reversed ranks [n-hi, n-lo) and map them back
+	n := z.Len()
+	rlo, rhi := limit(n-hi, n-lo, offset, count)
+	return z.collectReverse(n-1-rlo, rhi-rlo)
+}
+
+// RangeByRank returns the members between the ranks start and stop, both inclusive, in ascending order.
+// Negative ranks count from the highest score.
+func (z *Zset) RangeByRank(start, stop int) []ZMember {
+	lo, hi, ok := normalizeRange(start, stop, z.Len())
+	if !ok {
+		return []ZMember{}
+	}
+	return z.collect(lo, hi-lo)
+}
+
+// RevRangeByRank is like RangeByRank, but ranks count from the highest score.
+func (z *Zset) RevRangeByRank(start, stop int) []ZMember {
+	n := z.Len()
+	lo, hi, ok := normalizeRange(start, stop, n)
+	if !ok {
+		return []ZMember{}
+	}
+	return z.collectReverse(n-1-lo, hi-lo)
+}
+
+// Rank returns the rank of member, starting from 0 for the lowest score
+func (z *Zset) Rank(member string) (int, bool) {
+	score, ok := z.score(member)
+	if !ok {
+		return 0, false
+	}
+	if z.list == nil {
+		rank, _ := slices.BinarySearchFunc(z.small, ZMember{score, member}, compareZMembers)
+		return rank, true
+	}
+	return z.list.Rank(ZMember{score, member}), true
+}
+
+// RevRank returns the rank of member, starting from 0 for the highest score
+func (z *Zset) RevRank(member string) (int, bool) {
+	rank, ok := z.Rank(member)
+	if !ok {
+		return 0, false
+	}
+	return z.Len() - 1 - rank, true
+}
+
+// Remove removes the first member with the given score
 func (z *Zset) Remove(score float64) {
-	z.list.Remove(score)
+	if member, ok := z.Get(score); ok {
+		z.RemoveMember(member)
+	}
+}
+
+// RemoveMember removes member, and returns whether it was in the set
+func (z *Zset) RemoveMember(member string) bool {
+	score, ok := z.score(member)
+	if !ok {
+		return false
+	}
+	z.delete(ZMember{score, member})
+	return true
+}
+
+// RemoveRangeByScore removes the members with a score between min and max,
+// and returns the number of removed members
+func (z *Zset) RemoveRangeByScore(min, max ScoreBound) int {
+	return z.removeRange(z.scoreRange(min, max))
+}
+
+// RemoveRangeByRank removes the members between the ranks start and stop, both inclusive,
+// and returns the number of removed members. Negative ranks count from the highest score.
+func (z *Zset) RemoveRangeByRank(start, stop int) int {
+	lo, hi, ok := normalizeRange(start, stop, z.Len())
+	if !ok {
+		return 0
+	}
+	return z.removeRange(lo, hi)
+}
+
+// RangeByLex returns the members between min and max, when all members have the same score.
+// It skips offset members and returns at most count members, or all of them if count is negative.
+func (z *Zset) RangeByLex(min, max LexBound, offset, count int) []string {
+	lo, hi := z.lexRange(min, max)
+	lo, hi = limit(lo, hi, offset, count)
+	members := z.collect(lo, hi-lo)
+	strs := make([]string, len(members))
+	for i, m := range members {
+		strs[i] = m.Member
+	}
+	return strs
+}
+
+// LexCount returns the number of members between min and max, when all members have the same score
+func (z *Zset) LexCount(min, max LexBound) int {
+	lo, hi := z.lexRange(min, max)
+	return hi - lo
+}
+
+// RemoveRangeByLex removes the members between min and max, when all members have the same score,
+// and returns the number of removed members
+func (z *Zset) RemoveRangeByLex(min, max LexBound) int {
+	return z.removeRange(z.lexRange(min, max))
+}
+
+// PopMin removes and returns up to count members with the lowest scores
+func (z *Zset) PopMin(count int) []ZMember {
+	if count <= 0 {
+		return []ZMember{}
+	}
+	members := z.RangeByRank(0, count-1)
+	for _, m := range members {
+		z.RemoveMember(m.Member)
+	}
+	return members
+}
+
+// PopMax removes and returns up to count members with the highest scores
+func (z *Zset) PopMax(count int) []ZMember {
+	if count <= 0 {
+		return []ZMember{}
+	}
+	members := z.RevRangeByRank(0, count-1)
+	for _, m := range members {
+		z.RemoveMember(m.Member)
+	}
+	return members
 }